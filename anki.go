@@ -0,0 +1,134 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"html"
+	"io/ioutil"
+	"regexp"
+	"strings"
+)
+
+// Flashcards for Anki, written as a tab-separated file that Anki's importer
+// understands.  Cards come from `Q:` / `A:` lines and `term :: definition`
+// lines.  The deck follows the topic path, and every card gets a GUID made
+// from its note and question, so that importing again updates cards instead
+// of duplicating them.
+
+var questionPattern = regexp.MustCompile(`^\s*(?:[-*+]\s+)?Q:\s*(.*)$`)
+var answerPattern = regexp.MustCompile(`^\s*(?:[-*+]\s+)?A:\s*(.*)$`)
+var definitionPattern = regexp.MustCompile(`^\s*(?:[-*+]\s+)?(\S.*?)\s+::\s+(\S.*)$`)
+
+type Flashcard struct {
+	question string
+	answer   string
+}
+
+// Pull all cards out of the body of a note.
+func extractFlashcards(body string) []Flashcard {
+	cards := []Flashcard{}
+
+	lines := strings.Split(body, "\n")
+	fenced := fencedLines(lines)
+
+	question := ""
+	answer := ""
+	answering := false
+
+	finish := func() {
+		if question != "" && answering {
+			cards = append(cards, Flashcard{strings.TrimSpace(question), strings.TrimSpace(answer)})
+		}
+		question, answer, answering = "", "", false
+	}
+
+	for i, line := range lines {
+		if fenced[i] {
+			finish()
+			continue
+		}
+
+		if match := questionPattern.FindStringSubmatch(line); match != nil {
+			finish()
+			question = match[1]
+			continue
+		}
+
+		if match := answerPattern.FindStringSubmatch(line); match != nil && question != "" {
+			answer = match[1]
+			answering = true
+			continue
+		}
+
+		if strings.TrimSpace(line) == "" {
+			finish()
+			continue
+		}
+
+		// Questions and answers can run on over several lines.
+		if answering {
+			answer += "\n" + line
+			continue
+		} else if question != "" {
+			question += "\n" + line
+			continue
+		}
+
+		if match := definitionPattern.FindStringSubmatch(line); match != nil {
+			cards = append(cards, Flashcard{match[1], match[2]})
+		}
+	}
+
+	finish()
+	return cards
+}
+
+// Make a field safe for Anki's importer, which we tell to expect HTML.
+func ankiField(text string) string {
+	text = html.EscapeString(text)
+	text = strings.Replace(text, "\n", "<br>", -1)
+	return strings.Replace(text, "\t", " ", -1)
+}
+
+// Make a plain text column (the deck or tags) safe for the importer.  Only
+// the card fields are HTML, so these aren't escaped.
+func ankiColumn(text string) string {
+	return strings.NewReplacer("\t", " ", "\r", " ", "\n", " ").Replace(text)
+}
+
+// Render cards from every note as an Anki import file.  Decks sit under
+// `deck`, following the topic path.
+func dumpFlashcards(rootEntry Entry, deck string) string {
+	result := "#separator:tab\n#html:true\n#guid column:1\n#deck column:2\n#tags column:5\n"
+
+	rootEntry.walk("", func(path string, note Note) {
+		content, err := ioutil.ReadFile(note.path)
+		if err != nil {
+			panic(err)
+		}
+
+		noteDeck := deck
+		if path != "" {
+			noteDeck += "::" + strings.Replace(path, "/", "::", -1)
+		}
+
+		// Anki splits tags on spaces.
+		tags := []string{}
+		for _, tag := range note.tags() {
+			tags = append(tags, strings.Replace(tag, " ", "_", -1))
+		}
+
+		relPath := noteURL(path, note)
+
+		for _, card := range extractFlashcards(noteBody(string(content))) {
+			hash := sha1.Sum([]byte(relPath + "\x00" + card.question))
+			guid := base64.RawURLEncoding.EncodeToString(hash[:9])
+
+			fields := []string{guid, ankiColumn(noteDeck), ankiField(card.question), ankiField(card.answer),
+				ankiColumn(strings.Join(tags, " "))}
+			result += strings.Join(fields, "\t") + "\n"
+		}
+	})
+
+	return result
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Attachments are the images, PDFs and diagrams kept alongside notes.  They
+// can be listed in the index, rendered as a gallery, and checked for whether
+// any note still refers to them.
+
+var wikiLinkPattern = regexp.MustCompile(`!?\[\[([^\]|#]+)(?:#[^\]|]*)?(?:\|[^\]]*)?\]\]`)
+var htmlSourcePattern = regexp.MustCompile(`(?i)\b(?:src|href)\s*=\s*["']([^"']+)["']`)
+
+var imageExts = []string{".png", ".jpg", ".jpeg", ".gif", ".svg", ".webp"}
+
+// Visit the attachments of every topic, in index order.
+func (entry Entry) walkAttachments(path string, visit func(path string, attachment Note)) {
+	attachments := entry.attachments
+	sort.Stable(attachments)
+
+	for _, attachment := range attachments {
+		visit(path, attachment)
+	}
+
+	keys := make([]string, 0, len(entry.subTopics))
+	for key := range entry.subTopics {
+		keys = append(keys, string(key))
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		subPath := key
+		if path != "" {
+			subPath = path + "/" + subPath
+		}
+
+		entry.subTopics[Topic(key)].walkAttachments(subPath, visit)
+	}
+}
+
+// Find the files referred to from the body of a note at `notePath`.  Links
+// and HTML sources are resolved to full paths, while wiki links only name a
+// file, so they are returned as lowercase base names.
+func attachmentReferences(body string, notePath string) ([]string, []string) {
+	paths := []string{}
+	names := []string{}
+
+	targets := []string{}
+	for _, match := range markdownLinkPattern.FindAllStringSubmatch(body, -1) {
+		targets = append(targets, strings.Trim(match[1], "<>"))
+	}
+	for _, match := range htmlSourcePattern.FindAllStringSubmatch(body, -1) {
+		targets = append(targets, match[1])
+	}
+
+	for _, target := range targets {
+		if isExternalLink(target) {
+			continue
+		}
+
+		target = strings.SplitN(strings.SplitN(target, "#", 2)[0], "?", 2)[0]
+		if unescaped, err := url.PathUnescape(target); err == nil {
+			target = unescaped
+		}
+
+		paths = append(paths, filepath.Join(filepath.Dir(notePath), filepath.FromSlash(target)))
+	}
+
+	for _, match := range wikiLinkPattern.FindAllStringSubmatch(body, -1) {
+		names = append(names, strings.ToLower(pathBase(strings.TrimSpace(match[1]))))
+	}
+
+	return paths, names
+}
+
+// Print the attachments that no note refers to.  Returns true if there are
+// any.
+func reportUnusedAttachments(rootEntry Entry) bool {
+	paths := map[string]bool{}
+	names := map[string]bool{}
+
+	rootEntry.walk("", func(path string, note Note) {
+		content, err := ioutil.ReadFile(note.path)
+		if err != nil {
+			panic(err)
+		}
+
+		notePaths, noteNames := attachmentReferences(noteBody(string(content)), note.path)
+		for _, notePath := range notePaths {
+			paths[filepath.Clean(notePath)] = true
+		}
+		for _, name := range noteNames {
+			names[name] = true
+		}
+	})
+
+	unused := false
+
+	rootEntry.walkAttachments("", func(path string, attachment Note) {
+		if paths[filepath.Clean(attachment.path)] || names[strings.ToLower(attachment.name)] {
+			return
+		}
+
+		fmt.Fprintln(os.Stderr, attachment.path+": not referenced by any note")
+		unused = true
+	})
+
+	return unused
+}
+
+// Render every attachment as an HTML page, with thumbnails for images and
+// plain links for everything else, grouped by topic.
+func dumpAttachmentGallery(rootEntry Entry) string {
+	result := "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n"
+	result += "<title>Attachments</title>\n"
+	result += "<style>\n" +
+		"  .gallery { display: grid; grid-template-columns: repeat(auto-fill, minmax(160px, 1fr)); gap: 1em; }\n" +
+		"  .item { background: #f4f5f7; border-radius: 4px; padding: 0.5em; text-align: center; word-break: break-all; }\n" +
+		"  .item img { max-width: 100%; max-height: 120px; object-fit: contain; }\n" +
+		"  .file { display: block; height: 120px; line-height: 120px; color: #888; }\n" +
+		"</style>\n</head>\n<body>\n<h1>Attachments</h1>\n"
+
+	topic := "\x00"
+
+	rootEntry.walkAttachments("", func(path string, attachment Note) {
+		if path != topic {
+			if topic != "\x00" {
+				result += "</div>\n"
+			}
+
+			label := path
+			if label == "" {
+				label = "(top level)"
+			}
+
+			result += "<h2>" + html.EscapeString(label) + "</h2>\n<div class=\"gallery\">\n"
+			topic = path
+		}
+
+		link := html.EscapeString(noteURL(path, attachment))
+		name := html.EscapeString(attachment.name)
+
+		preview := "<span class=\"file\">" + html.EscapeString(strings.ToUpper(strings.TrimPrefix(filepath.Ext(attachment.name), "."))) + "</span>"
+		if containsFold(imageExts, filepath.Ext(attachment.name)) {
+			preview = fmt.Sprintf("<img src=\"%s\" alt=\"%s\" loading=\"lazy\">", link, name)
+		}
+
+		result += fmt.Sprintf("<div class=\"item\"><a href=\"%s\">%s<br>%s</a></div>\n", link, preview, name)
+	})
+
+	if topic != "\x00" {
+		result += "</div>\n"
+	}
+
+	return result + "</body>\n</html>\n"
+}
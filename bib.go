@@ -0,0 +1,350 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Bibliography support.  References live in a shared BibTeX file, and notes
+// cite them Pandoc-style, like `[@knuth1984]` or `[see @a, p. 3; @b]`.
+
+var citationPattern = regexp.MustCompile(`\[[^\[\]]*@[^\[\]]*\]`)
+var citationKeyPattern = regexp.MustCompile(`(?:^|[\s;\[])-?@([\w:.#$%&+?<>~/-]*\w)`)
+
+type BibEntry struct {
+	kind   string
+	key    string
+	fields map[string]string
+}
+
+// A tiny BibTeX reader: enough for entries with braced, quoted or bare field
+// values, joined with `#`.  Bare words are looked up in @string macros;
+// @preamble and @comment blocks are skipped.
+type bibParser struct {
+	text   string
+	pos    int
+	macros map[string]string
+}
+
+// Move past the current character, without running off the end.
+func (parser *bibParser) next() {
+	if parser.pos < len(parser.text) {
+		parser.pos++
+	}
+}
+
+func (parser *bibParser) done() bool {
+	return parser.pos >= len(parser.text)
+}
+
+// Move to just past the next `@` that starts an entry, returning false if
+// there isn't one.  Entries start a line or follow whitespace, so e-mail
+// addresses in comments don't count.
+func (parser *bibParser) nextEntry() bool {
+	for {
+		at := strings.Index(parser.text[parser.pos:], "@")
+		if at < 0 {
+			return false
+		}
+
+		parser.pos += at + 1
+		if parser.pos == 1 || strings.ContainsRune(" \t\r\n", rune(parser.text[parser.pos-2])) {
+			return true
+		}
+	}
+}
+
+func (parser *bibParser) skipSpace() {
+	for parser.pos < len(parser.text) && strings.ContainsRune(" \t\r\n", rune(parser.text[parser.pos])) {
+		parser.pos++
+	}
+}
+
+// Read up to (not including) the first of `stops`.
+func (parser *bibParser) readUntil(stops string) string {
+	start := parser.pos
+	for parser.pos < len(parser.text) && strings.ContainsRune(stops, rune(parser.text[parser.pos])) == false {
+		parser.pos++
+	}
+
+	return strings.TrimSpace(parser.text[start:parser.pos])
+}
+
+// Read a braced group, starting at the opening brace.  Returns the contents
+// without the outer braces.
+func (parser *bibParser) readBraced() string {
+	depth := 0
+	start := parser.pos + 1
+
+	for ; parser.pos < len(parser.text); parser.pos++ {
+		switch parser.text[parser.pos] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				parser.pos++
+				return parser.text[start : parser.pos-1]
+			}
+		}
+	}
+
+	return parser.text[start:]
+}
+
+func (parser *bibParser) readValue() string {
+	parser.skipSpace()
+	if parser.pos >= len(parser.text) {
+		return ""
+	}
+
+	switch parser.text[parser.pos] {
+	case '{':
+		return parser.readBraced()
+
+	case '"':
+		parser.pos++
+		start := parser.pos
+		depth := 0
+
+		for ; parser.pos < len(parser.text); parser.pos++ {
+			char := parser.text[parser.pos]
+			if char == '{' {
+				depth++
+			} else if char == '}' {
+				depth--
+			} else if char == '"' && depth == 0 {
+				break
+			}
+		}
+
+		value := parser.text[start:parser.pos]
+		parser.next()
+		return value
+	}
+
+	word := parser.readUntil(",}#")
+	if macro, found := parser.macros[strings.ToLower(word)]; found {
+		return macro
+	}
+
+	return word
+}
+
+// Read a field value, along with anything concatenated onto it.
+func (parser *bibParser) readField() string {
+	value := parser.readValue()
+	parser.skipSpace()
+
+	for parser.pos < len(parser.text) && parser.text[parser.pos] == '#' {
+		parser.next()
+		value += parser.readValue()
+		parser.skipSpace()
+	}
+
+	return value
+}
+
+func parseBibTeX(text string) []BibEntry {
+	entries := []BibEntry{}
+	parser := &bibParser{text: text, macros: map[string]string{}}
+
+	for parser.nextEntry() {
+		kind := strings.ToLower(parser.readUntil("{("))
+		parser.skipSpace()
+
+		if parser.done() {
+			break
+		}
+
+		if kind == "comment" || kind == "preamble" {
+			parser.readBraced()
+			continue
+		}
+
+		if kind == "string" {
+			parser.next()
+			name := strings.ToLower(parser.readUntil("="))
+			if parser.done() {
+				break
+			}
+
+			parser.next()
+			parser.macros[name] = parser.readField()
+			parser.readUntil("}")
+			continue
+		}
+
+		parser.next()
+		entry := BibEntry{kind: kind, key: parser.readUntil(",}"), fields: map[string]string{}}
+
+		for parser.pos < len(parser.text) && parser.text[parser.pos] == ',' {
+			parser.next()
+			parser.skipSpace()
+
+			name := strings.ToLower(parser.readUntil("=,}"))
+			if parser.done() || parser.text[parser.pos] != '=' {
+				break
+			}
+
+			parser.next()
+			entry.fields[name] = parser.readField()
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries
+}
+
+// Strip the braces that BibTeX uses to protect capitalisation.
+func bibText(value string) string {
+	value = strings.NewReplacer("{", "", "}", "").Replace(value)
+	return strings.Join(strings.Fields(value), " ")
+}
+
+// Format an entry as a one-line reference.
+func formatReference(entry BibEntry) string {
+	authors := strings.Split(bibText(entry.fields["author"]), " and ")
+	if entry.fields["author"] == "" {
+		authors = strings.Split(bibText(entry.fields["editor"]), " and ")
+	}
+
+	author := authors[0]
+	if len(authors) > 1 {
+		author = strings.Join(authors[:len(authors)-1], ", ") + " and " + authors[len(authors)-1]
+	}
+
+	result := author
+	if year := bibText(entry.fields["year"]); year != "" {
+		result += " (" + year + ")"
+	}
+
+	if result != "" {
+		result += ". "
+	}
+	result += "*" + bibText(entry.fields["title"]) + "*."
+
+	for _, field := range []string{"journal", "booktitle", "publisher"} {
+		if value := bibText(entry.fields[field]); value != "" {
+			result += " " + value + "."
+			break
+		}
+	}
+
+	if url := entry.fields["url"]; url != "" {
+		result += " <" + url + ">"
+	} else if doi := entry.fields["doi"]; doi != "" {
+		result += " <https://doi.org/" + doi + ">"
+	}
+
+	return strings.TrimSpace(result)
+}
+
+// Find where the citations in `line` are.  A bracket followed by a
+// parenthesis is a link, not a citation.
+func citationSpans(line string) [][]int {
+	spans := [][]int{}
+
+	for _, span := range citationPattern.FindAllStringIndex(line, -1) {
+		if span[1] < len(line) && line[span[1]] == '(' {
+			continue
+		}
+		spans = append(spans, span)
+	}
+
+	return spans
+}
+
+// Drop the citations from `line`.
+func stripCitations(line string) string {
+	spans := citationSpans(line)
+
+	for i := len(spans) - 1; i >= 0; i-- {
+		line = line[:spans[i][0]] + line[spans[i][1]:]
+	}
+
+	return line
+}
+
+// Find the keys of every citation in the body of a note.
+func extractCitations(body string) []string {
+	keys := []string{}
+
+	lines := strings.Split(body, "\n")
+	fenced := fencedLines(lines)
+
+	for i, line := range lines {
+		if fenced[i] {
+			continue
+		}
+
+		line = codeSpanPattern.ReplaceAllString(line, "")
+		for _, span := range citationSpans(line) {
+			citation := line[span[0]:span[1]]
+			for _, match := range citationKeyPattern.FindAllStringSubmatch(citation, -1) {
+				keys = append(keys, match[1])
+			}
+		}
+	}
+
+	return keys
+}
+
+// Render the bibliography, with the notes that cite each reference.  Unknown
+// keys are reported on stderr.
+func dumpBibliography(rootEntry Entry, entries []BibEntry) string {
+	known := map[string]bool{}
+	for _, entry := range entries {
+		known[entry.key] = true
+	}
+
+	citedBy := map[string][]string{}
+
+	rootEntry.walk("", func(path string, note Note) {
+		content, err := ioutil.ReadFile(note.path)
+		if err != nil {
+			panic(err)
+		}
+
+		relPath := noteURL(path, note)
+
+		for _, key := range extractCitations(noteBody(string(content))) {
+			if known[key] == false {
+				fmt.Fprintln(os.Stderr, note.path+": unknown citation key "+key)
+				continue
+			}
+
+			notes := citedBy[key]
+			if len(notes) == 0 || notes[len(notes)-1] != relPath {
+				citedBy[key] = append(notes, relPath)
+			}
+		}
+	})
+
+	sort.SliceStable(entries, func(i int, j int) bool {
+		return strings.ToLower(entries[i].key) < strings.ToLower(entries[j].key)
+	})
+
+	result := "# Bibliography\n"
+
+	for _, entry := range entries {
+		result += "\n## " + entry.key + "\n\n" + formatReference(entry) + "\n\n"
+
+		if len(citedBy[entry.key]) == 0 {
+			result += "Not cited by any note.\n"
+			continue
+		}
+
+		result += "Cited by:\n\n"
+		for _, relPath := range citedBy[entry.key] {
+			result += fmt.Sprintf("- [%s](%s)\n", pathBase(relPath), relPath)
+		}
+	}
+
+	return result
+}
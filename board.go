@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// A board groups notes into columns by the value of one front matter field
+// (like `status`), as either a Markdown table or an HTML page.
+
+type BoardCard struct {
+	note Note
+	url  string
+}
+
+type BoardColumn struct {
+	name  string
+	cards []BoardCard
+}
+
+// Sort the cards in a column by `key`: "name", "date" (oldest first) or
+// "date-desc".
+func sortCards(cards []BoardCard, key string) {
+	sort.SliceStable(cards, func(i int, j int) bool {
+		a, b := cards[i].note, cards[j].note
+
+		switch key {
+		case "date":
+			return a.timestamp.Before(b.timestamp)
+		case "date-desc":
+			return a.timestamp.After(b.timestamp)
+		}
+		return a.name < b.name
+	})
+}
+
+// Parse a sort spec like `date-desc,superseded=name` into a default sort key
+// and per-column overrides.
+func parseBoardSort(spec string) (string, map[string]string) {
+	defaultKey := "name"
+	keys := map[string]string{}
+
+	for _, item := range splitList(spec) {
+		parts := strings.SplitN(item, "=", 2)
+
+		key := parts[len(parts)-1]
+		if key != "name" && key != "date" && key != "date-desc" {
+			panic("Unknown board sort " + key + ", terminating.")
+		}
+
+		if len(parts) == 1 {
+			defaultKey = key
+		} else {
+			keys[parts[0]] = key
+		}
+	}
+
+	return defaultKey, keys
+}
+
+// Group every note with a value for `field` into columns.  Columns listed in
+// `order` come first, in that order, followed by any others alphabetically.
+func buildBoard(rootEntry Entry, field string, order []string, sortSpec string) []BoardColumn {
+	cards := map[string][]BoardCard{}
+
+	rootEntry.walk("", func(path string, note Note) {
+		value := strings.ToLower(note.meta.value(field))
+		if value != "" {
+			cards[value] = append(cards[value], BoardCard{note, noteURL(path, note)})
+		}
+	})
+
+	names := []string{}
+	listed := map[string]bool{}
+
+	for _, name := range order {
+		names = append(names, strings.ToLower(name))
+		listed[strings.ToLower(name)] = true
+	}
+
+	others := []string{}
+	for name := range cards {
+		if listed[name] == false {
+			others = append(others, name)
+		}
+	}
+
+	sort.Strings(others)
+	names = append(names, others...)
+
+	defaultKey, keys := parseBoardSort(sortSpec)
+	columns := []BoardColumn{}
+
+	for _, name := range names {
+		key, found := keys[name]
+		if found == false {
+			key = defaultKey
+		}
+
+		sortCards(cards[name], key)
+		columns = append(columns, BoardColumn{name, cards[name]})
+	}
+
+	return columns
+}
+
+func cardName(card BoardCard, fileExt string) string {
+	return card.note.displayName(fileExt)
+}
+
+func dumpBoardMarkdown(columns []BoardColumn, field string, fileExt string) string {
+	result := "# Notes by " + field + "\n\n"
+
+	header := "|"
+	rule := "|"
+	rows := 0
+
+	for _, column := range columns {
+		header += fmt.Sprintf(" %s (%d) |", column.name, len(column.cards))
+		rule += " --- |"
+
+		if len(column.cards) > rows {
+			rows = len(column.cards)
+		}
+	}
+
+	result += header + "\n" + rule + "\n"
+
+	for row := 0; row < rows; row++ {
+		line := "|"
+
+		for _, column := range columns {
+			cell := ""
+			if row < len(column.cards) {
+				card := column.cards[row]
+				name := strings.Replace(cardName(card, fileExt), "|", "\\|", -1)
+				cell = fmt.Sprintf("[%s](%s)", name, card.url)
+			}
+
+			line += " " + cell + " |"
+		}
+
+		result += line + "\n"
+	}
+
+	return result
+}
+
+func dumpBoardHTML(columns []BoardColumn, field string, fileExt string) string {
+	result := "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n"
+	result += "<title>Notes by " + html.EscapeString(field) + "</title>\n"
+	result += "<style>\n" +
+		"  .board { display: flex; gap: 1em; align-items: flex-start; }\n" +
+		"  .column { flex: 1; background: #f4f5f7; border-radius: 4px; padding: 0.5em; }\n" +
+		"  .card { background: white; border-radius: 4px; padding: 0.5em; margin: 0.5em 0; }\n" +
+		"  .date { color: #888; font-size: small; }\n" +
+		"</style>\n</head>\n<body>\n"
+	result += "<h1>Notes by " + html.EscapeString(field) + "</h1>\n<div class=\"board\">\n"
+
+	for _, column := range columns {
+		result += fmt.Sprintf("<div class=\"column\">\n<h2>%s (%d)</h2>\n",
+			html.EscapeString(column.name), len(column.cards))
+
+		for _, card := range column.cards {
+			result += fmt.Sprintf("<div class=\"card\"><a href=\"%s\">%s</a> <span class=\"date\">%s</span></div>\n",
+				html.EscapeString(card.url), html.EscapeString(cardName(card, fileExt)),
+				card.note.timestamp.Format("02 Jan 2006"))
+		}
+
+		result += "</div>\n"
+	}
+
+	return result + "</div>\n</body>\n</html>\n"
+}
+
+// Render the board as HTML if `path` ends in .html, and Markdown otherwise.
+func dumpBoard(path string, columns []BoardColumn, field string, fileExt string) string {
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext == ".html" || ext == ".htm" {
+		return dumpBoardHTML(columns, field, fileExt)
+	}
+
+	return dumpBoardMarkdown(columns, field, fileExt)
+}
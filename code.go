@@ -4,7 +4,9 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -16,7 +18,51 @@ type Topic string
 
 type Note struct {
 	name      string
+	path      string
 	timestamp time.Time
+	meta      FrontMatter
+
+	// Pinned notes are listed first, lowest `pinOrder` first (0 for none).
+	pinned   bool
+	pinOrder int
+
+	// Where the note really lives (relative to the root), when it is listed
+	// under some other topic.  A note listed under several topics is only
+	// visited once by walk; the other copies are marked as duplicates.
+	link      string
+	duplicate bool
+
+	// Inline #hashtags found in the body, and the tags that the note ends up
+	// with once we've picked which sources count.
+	hashtags []string
+	tagList  []string
+
+	// Notes in other formats than Markdown, with the title that the format
+	// found in them.  Both are left empty for Markdown notes.
+	format NoteFormat
+	title  string
+}
+
+func (note Note) tags() []string {
+	return note.tagList
+}
+
+// The name of a note without its extension.
+func (note Note) stem(fileExt string) string {
+	if note.format != nil {
+		return strings.TrimSuffix(note.name, filepath.Ext(note.name))
+	}
+
+	return strings.TrimSuffix(note.name, fileExt)
+}
+
+// What to call a note in listings.
+func (note Note) displayName(fileExt string) string {
+	if note.title != "" {
+		return note.title
+	}
+
+	return note.stem(fileExt)
 }
 
 type Notes []Note
@@ -37,34 +83,189 @@ func (notes Notes) Swap(i int, j int) {
 type Entry struct {
 	notes     Notes
 	subTopics map[Topic]*Entry
+
+	// Other files kept alongside the notes, like images and PDFs.  These are
+	// recorded like notes, but never parsed.
+	attachments Notes
+
+	// Whether the topic only comes from `topics:` front matter, so there is
+	// no directory to link to.
+	virtual bool
 }
 
 // Constructor for Entry
 func blankEntry() Entry {
-	return Entry{notes: []Note{}, subTopics: map[Topic]*Entry{}}
+	return Entry{notes: []Note{}, subTopics: map[Topic]*Entry{}, attachments: []Note{}}
+}
+
+// Settings that control how the index is rendered.
+type DumpOptions struct {
+	fileExt string
+
+	// List each note's H2 and H3 headings underneath its link.
+	deepToc bool
+
+	// Start the index with a table of contents of all topics.
+	toc bool
+
+	// Only list this many of the most recent notes in each topic (0 for all).
+	recent int
+
+	// Split the index into pages of roughly this many bytes (0 for one page),
+	// named after `outputName`.
+	pageSize   int
+	outputName string
+
+	// List each topic's attachments after its notes.
+	attachments bool
+}
+
+// Name of the file for the given (zero-based) page of the index at `path`.
+// The first page keeps the name as is; the rest get numbered.
+func pageFileName(path string, page int) string {
+	if page == 0 {
+		return path
+	}
+
+	ext := filepath.Ext(path)
+	return fmt.Sprintf("%s-%d%s", strings.TrimSuffix(path, ext), page+1, ext)
 }
 
-func (entry Entry) dump(path string, indent int, fileExt string) string {
+// Check whether the file at `path` is page `page` of an index that we wrote
+// earlier.  Numbered pages always start with their page header, so a note
+// that happens to share the name is left alone.
+func isIndexPage(path string, page int) bool {
+	if page == 0 {
+		return true
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false
+	}
+
+	return strings.HasPrefix(string(content), fmt.Sprintf("# Notes (page %d of ", page+1))
+}
+
+// Pick the `count` most recently modified of `notes`, keeping them in the
+// usual order.  Pinned notes always make the cut first.
+func recentNotes(notes Notes, count int) Notes {
+	recent := make(Notes, len(notes))
+	copy(recent, notes)
+
+	sort.SliceStable(recent, func(i int, j int) bool {
+		if recent[i].pinned != recent[j].pinned {
+			return recent[i].pinned
+		}
+		return recent[i].timestamp.After(recent[j].timestamp)
+	})
+
+	recent = recent[:count]
+	sort.Stable(recent)
+
+	return recent
+}
+
+// Render the H2 and H3 headings of `note` as a nested list of links into it.
+func noteOutline(note Note, url string, indentStr string) string {
+	result := ""
+
+	content, err := ioutil.ReadFile(note.path)
+	if err != nil {
+		panic(err)
+	}
+
+	// Anchors depend on every heading in the note, not just the ones listed.
+	slugger := newSlugger()
+
+	for _, heading := range parseHeadings(strings.Split(string(content), "\n")) {
+		slug := slugger.slug(heading.text)
+
+		if heading.level == 2 || heading.level == 3 {
+			headingIndent := indentStr + strings.Repeat("  ", heading.level-1)
+			text := plainHeadingText(heading.text)
+
+			result += fmt.Sprintf("%s- [%s](%s#%s)\n", headingIndent, text, url, slug)
+		}
+	}
+
+	return result
+}
+
+// Link to `note`, which is listed in the topic at `path`, relative to the
+// root.
+func noteURL(path string, note Note) string {
+	if note.link != "" {
+		return note.link
+	}
+
+	if path == "" {
+		return note.name
+	}
+
+	return path + "/" + note.name
+}
+
+// Render the index line for a single note.
+func noteLine(note Note, url string, indentStr string, opts DumpOptions) string {
+	timestamp := note.timestamp.Format("02 Jan 2006")
+	name := note.displayName(opts.fileExt)
+
+	return fmt.Sprintf("%s- [%s](%s) [%s]\n", indentStr, name, url, timestamp)
+}
+
+// Render the heading of a section of the index, at the level that goes with
+// `indent`.
+func indexHeading(indent int, name string) string {
+	return fmt.Sprintf("\n%s%s %s\n", strings.Repeat(" ", indent), strings.Repeat("#", indent), name)
+}
+
+func (entry Entry) dump(path string, indent int, opts DumpOptions) string {
 	result := ""
 	indentStr := strings.Repeat(" ", indent)
 
 	notes := entry.notes
 	sort.Stable(notes)
 
+	if opts.recent > 0 && len(notes) > opts.recent {
+		notes = recentNotes(notes, opts.recent)
+	}
+
+	sortPinned(notes)
+
 	for _, note := range notes {
-		timestamp := note.timestamp.Format("02 Jan 2006")
+		url := noteURL(path, note)
+		result += noteLine(note, url, indentStr, opts)
 
-		url := note.name
-		if path != "" {
-			url = path + "/" + url
+		if opts.deepToc {
+			result += noteOutline(note, url, indentStr)
 		}
+	}
 
-		name := note.name[:len(note.name)-len(fileExt)]
+	if len(notes) < len(entry.notes) && entry.virtual == false {
+		url := strings.Replace(path, " ", "%20", -1)
+		if url == "" {
+			url = "."
+		}
 
-		dump := fmt.Sprintf("%s- [%s](%s) [%s]", indentStr, name, url, timestamp)
+		dump := fmt.Sprintf("%s- [See all %d notes](%s)", indentStr, len(entry.notes), url)
 		result += dump + "\n"
 	}
 
+	if opts.attachments && len(entry.attachments) > 0 {
+		links := []string{}
+
+		attachments := entry.attachments
+		sort.Stable(attachments)
+
+		for _, attachment := range attachments {
+			url := strings.Replace(noteURL(path, attachment), " ", "%20", -1)
+			links = append(links, fmt.Sprintf("[%s](%s)", attachment.name, url))
+		}
+
+		result += indentStr + "- Attachments: " + strings.Join(links, ", ") + "\n"
+	}
+
 	// Make a list of all keys so that we can sort them, and thus iterate over
 	// all keys in sorted order.
 	keys := make([]string, 0, len(entry.subTopics))
@@ -81,32 +282,165 @@ func (entry Entry) dump(path string, indent int, fileExt string) string {
 			subPath = path + "/" + subPath
 		}
 
-		name := key
-		subTopic := Topic(key)
+		result += indexHeading(indent, key)
 
-		headingMarker := strings.Repeat("#", indent)
+		subEntry := entry.subTopics[Topic(key)]
+		result += subEntry.dump(subPath, indent+1, opts)
+	}
 
-		dump := fmt.Sprintf("\n%s%s %s", indentStr, headingMarker, name)
-		result += dump + "\n"
+	return result
+}
 
-		subEntry := entry.subTopics[subTopic]
-		result += subEntry.dump(subPath, indent+1, fileExt)
+// Find the topic at `path` (relative to `entry`), or nil if there isn't one.
+func (entry *Entry) lookup(path string) *Entry {
+	if path == "" {
+		return entry
 	}
 
-	return result
+	for _, name := range strings.Split(path, "/") {
+		subEntry, found := entry.subTopics[Topic(name)]
+		if found == false {
+			return nil
+		}
+
+		entry = subEntry
+	}
+
+	return entry
 }
 
-func (entry Entry) Dump(fileExt string) string {
-	result := "# Notes\n"
-	result += entry.dump("", 2, fileExt)
+// Visit every note under `entry`, in the same order that Dump lists them,
+// along with the topic path (relative to the root) that the note is listed
+// in.
+func (entry Entry) walk(path string, visit func(path string, note Note)) {
+	notes := entry.notes
+	sort.Stable(notes)
+
+	for _, note := range notes {
+		if note.duplicate == false {
+			visit(path, note)
+		}
+	}
+
+	keys := make([]string, 0, len(entry.subTopics))
+
+	for key := range entry.subTopics {
+		keys = append(keys, string(key))
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		subPath := key
+		if path != "" {
+			subPath = path + "/" + subPath
+		}
+
+		entry.subTopics[Topic(key)].walk(subPath, visit)
+	}
+}
+
+// Build a table of contents for the index.  The headings are parsed back out
+// of the rendered pages, so the anchors come out exactly as GitHub numbers
+// them.
+func indexToc(pages []string, opts DumpOptions) string {
+	result := ""
+
+	for page, text := range pages {
+		slugger := newSlugger()
+
+		pageName := ""
+		if page > 0 {
+			pageName = pageFileName(opts.outputName, page)
+		}
+
+		for _, heading := range parseHeadings(strings.Split(text, "\n")) {
+			slug := slugger.slug(heading.text)
+
+			// Skip the title of each page.
+			if heading.level == 1 {
+				continue
+			}
+
+			indentStr := strings.Repeat("  ", heading.level-2)
+			text := plainHeadingText(heading.text)
+			result += fmt.Sprintf("%s- [%s](%s#%s)\n", indentStr, text, pageName, slug)
+		}
+	}
 
 	return result
 }
 
+// Split the body of the index into pages of about `pageSize` bytes.  Pages
+// only break between topics, so a big topic can overflow its page.
+func paginate(body string, pageSize int) []string {
+	if pageSize <= 0 {
+		return []string{body}
+	}
+
+	pages := []string{}
+	page := ""
+
+	// Every topic heading is preceded by a blank line.
+	for i, block := range strings.Split(body, "\n\n") {
+		if i == 0 {
+			page = block
+		} else if page != "" && len(page)+len(block)+2 > pageSize {
+			pages = append(pages, page+"\n")
+			page = "\n" + block
+		} else {
+			page += "\n\n" + block
+		}
+	}
+
+	return append(pages, page)
+}
+
+// Render the index as one or more pages.
+func (entry Entry) Dump(opts DumpOptions) []string {
+	bodies := paginate(entry.dumpPinned(opts)+entry.dump("", 2, opts), opts.pageSize)
+	pages := make([]string, len(bodies))
+
+	for page, body := range bodies {
+		title := "# Notes\n"
+		if len(bodies) > 1 {
+			title = fmt.Sprintf("# Notes (page %d of %d)\n", page+1, len(bodies))
+		}
+
+		pages[page] = title + body
+	}
+
+	if opts.toc {
+		pages[0] = strings.Replace(pages[0], "\n", "\n\n"+indexToc(pages, opts)+"\n", 1)
+	}
+
+	// Link the pages together.
+	if len(pages) > 1 {
+		for page := range pages {
+			links := []string{}
+
+			if page > 0 {
+				links = append(links, "[← Previous]("+pageFileName(opts.outputName, page-1)+")")
+			}
+			if page+1 < len(pages) {
+				links = append(links, "[Next →]("+pageFileName(opts.outputName, page+1)+")")
+			}
+
+			pages[page] += "\n" + strings.Join(links, " | ") + "\n"
+		}
+	}
+
+	return pages
+}
+
 // Key traversal function.  Start with `basePath`, check for files with
 // `fileExt` extension, add them (and subdirs) to `entry`, but make sure you
-// don't add the same file as `outInfo`.
-func __traverseDir(basePath string, fileExt string, entry *Entry, outInfo os.FileInfo) {
+// don't add any of the files in `outInfos`.  Files that one of `formats`
+// handles are added as notes too, and files with one of the `attachmentExts`
+// extensions are added as attachments.
+func __traverseDir(basePath string, fileExt string, entry *Entry, outInfos []os.FileInfo,
+	attachmentExts []string, formats []NoteFormat) {
+
 	files, err := ioutil.ReadDir(basePath)
 
 	if err != nil {
@@ -132,23 +466,65 @@ func __traverseDir(basePath string, fileExt string, entry *Entry, outInfo os.Fil
 
 				// Recurse down to the next level.
 				subEntry := entry.subTopics[subTopic]
-				__traverseDir(fullPath, fileExt, subEntry, outInfo)
+				__traverseDir(fullPath, fileExt, subEntry, outInfos, attachmentExts, formats)
 			}
-		} else if strings.HasSuffix(file.Name(), fileExt) {
-			// Include this note only if it is not the output file.
-			if os.SameFile(outInfo, file) == false {
+		} else if format := formatFor(name, formats); strings.HasSuffix(name, fileExt) || format != nil {
+			// Include this note only if it is not an output file.
+			isOutput := false
+			for _, outInfo := range outInfos {
+				isOutput = isOutput || os.SameFile(outInfo, file)
+			}
+
+			if isOutput == false {
+				content, err := ioutil.ReadFile(fullPath)
+				if err != nil {
+					panic(err)
+				}
 
-				note := Note{name: name, timestamp: file.ModTime()}
+				meta, _ := parseFrontMatter(string(content))
+				body := noteBody(string(content))
+				title := ""
+
+				if strings.HasSuffix(name, fileExt) {
+					format = nil
+				} else {
+					title, meta, body = format.Parse(string(content))
+				}
+
+				pinOrder, _ := strconv.Atoi(meta.value("pin_order"))
+
+				note := Note{name: name, path: fullPath, timestamp: file.ModTime(), meta: meta,
+					pinned: meta.flag("pinned"), pinOrder: pinOrder, hashtags: extractHashtags(body),
+					format: format, title: title}
 				entry.notes = append(entry.notes, note)
 			}
+		} else if strings.HasPrefix(name, ".") == false && containsFold(attachmentExts, filepath.Ext(name)) {
+			attachment := Note{name: name, path: fullPath, timestamp: file.ModTime()}
+			entry.attachments = append(entry.attachments, attachment)
 		}
 	}
 }
 
+// Split a comma-separated flag value, dropping empty items.
+func splitList(spec string) []string {
+	items := []string{}
+
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
 // Top-level traversal function.
-func traverseDir(basePath string, fileExt string, outInfo os.FileInfo) Entry {
+func traverseDir(basePath string, fileExt string, outInfos []os.FileInfo, attachmentExts []string,
+	formats []NoteFormat) Entry {
 	rootEntry := blankEntry()
-	__traverseDir(basePath, fileExt, &rootEntry, outInfo)
+	__traverseDir(basePath, fileExt, &rootEntry, outInfos, attachmentExts, formats)
+	rootEntry.placeVirtualTopics()
 
 	return rootEntry
 }
@@ -156,23 +532,252 @@ func traverseDir(basePath string, fileExt string, outInfo os.FileInfo) Entry {
 func main() {
 	outputFile := flag.String("out", "README.md", "Path to output file.")
 	fileExt := flag.String("ext", ".md", "Index files that have this extension.")
+	deepToc := flag.Bool("deep-toc", false, "List the H2 and H3 headings of each note under its link.")
+	toc := flag.Bool("toc", false, "Start the index with a table of contents of all topics.")
+	recent := flag.Int("recent", 0, "Only list this many of the most recent notes per topic (0 for all).")
+	obsidian := flag.Bool("obsidian", false, "Treat the tree as an Obsidian vault, following its .obsidian settings.")
+	importFrom := flag.String("import-from", "", "Comma-separated Evernote .enex files and Notion export .zip files to import.")
+	formats := flag.String("formats", "",
+		"Comma-separated formats (org, ipynb, asciidoc, rst) to index alongside -ext notes.")
+	attachmentExts := flag.String("attachment-exts", ".png,.jpg,.jpeg,.gif,.svg,.webp,.pdf,.drawio,.excalidraw",
+		"Comma-separated extensions of files recorded as attachments.")
+	listAttachments := flag.Bool("list-attachments", false, "List each topic's attachments in the index.")
+	attachmentsHTML := flag.String("attachments-html", "", "Also write an HTML gallery of all attachments to this file.")
+	pageSize := flag.Int("page-size", 0, "Split the index into pages of about this many bytes (0 for one page).")
+	series := flag.String("series", "", "Comma-separated topics whose notes get previous/next links.")
+	tagSources := flag.String("tag-sources", "frontmatter,inline",
+		"Comma-separated places that note tags come from (frontmatter, inline).")
+	pinsFile := flag.String("pins", "", "Path to file listing notes to pin, one per line, in pin order.")
+	board := flag.String("board", "", "Also write a board of notes grouped by -board-field to this file (.md or .html).")
+	boardField := flag.String("board-field", "status", "Front matter field that picks a note's board column.")
+	boardColumns := flag.String("board-columns", "", "Comma-separated board columns, in order.")
+	boardSort := flag.String("board-sort", "name",
+		"Sort for board columns (name, date, date-desc), with column=sort overrides.")
+	people := flag.String("people", "", "Also write an index of people mentioned in notes to this file.")
+	peopleAliases := flag.String("people-aliases", "", "Path to file mapping names to their other spellings.")
+	ics := flag.String("ics", "", "Also write an iCalendar file of dates found in notes to this file.")
+	icsFields := flag.String("ics-fields", "due,deadline", "Comma-separated front matter fields that hold dates.")
+	icsBaseURL := flag.String("ics-base-url", "", "URL that note paths are relative to, for links in calendar events.")
+	lintRules := flag.String("lint-rules", "",
+		"Comma-separated rule=severity overrides for lint (off, warning, error).")
+	noteNaming := flag.String("note-naming", "",
+		"Comma-separated naming rules for notes (lowercase, no-spaces, kebab-case, date-prefix).")
+	topicNaming := flag.String("topic-naming", "",
+		"Comma-separated naming rules for topic directories.")
+	namingFix := flag.Bool("naming-fix", false, "Rename files that break naming rules, and update links.")
+	wikiDir := flag.String("wiki-dir", "wiki", "Directory that wiki writes GitHub wiki pages to.")
+	publishDir := flag.String("publish-dir", "public",
+		"Directory that publish writes notes to (keep it outside the notes directory).")
+	includeDepth := flag.Int("include-depth", 5, "How deeply transclusions may nest when publishing.")
+	ankiOut := flag.String("anki-out", "flashcards.txt", "File that anki writes flashcards to.")
+	ankiDeck := flag.String("anki-deck", "Notes", "Anki deck that holds the flashcards, with topics as subdecks.")
+	snippetsOut := flag.String("snippets-out", "SNIPPETS.md",
+		"File that snippets writes the code catalog to, with a .json twin alongside.")
+	snippetLangs := flag.String("snippet-langs", "", "Comma-separated languages to catalog (all if empty).")
+	glossaryOut := flag.String("glossary-out", "GLOSSARY.md", "File that glossary writes the collected terms to.")
+	bibFile := flag.String("bib", "", "Path to the BibTeX file that notes cite from.")
+	bibOut := flag.String("bib-out", "BIBLIOGRAPHY.md", "File that bib writes the bibliography to.")
+	linksCheck := flag.Bool("links-check", false, "Have the links command check that every external link works.")
+	linksConcurrency := flag.Int("links-concurrency", 8, "How many links to check at once.")
+	linksRetries := flag.Int("links-retries", 2, "How many times to retry links that fail with a network or server error.")
+	linksTimeout := flag.Duration("links-timeout", 10*time.Second, "Timeout for each link check.")
+	linksCache := flag.String("links-cache", "", "Path to a file caching link check results between runs.")
+	linksCacheTTL := flag.Duration("links-cache-ttl", 24*time.Hour, "How long cached link check results stay valid.")
+	scanHosts := flag.String("scan-hosts", "", "Comma-separated internal host names or domains to scan for.")
+	scanAllow := flag.String("scan-allow", "", "Path to allowlist file for the secret scanner.")
+	scanStrict := flag.Bool("scan-strict", false, "Refuse to write the index if the secret scanner finds anything.")
 
 	flag.Parse()
 	args := flag.Args()
 
+	// An optional command may precede the path, e.g. `parse-notes lint notes`.
+	command := "index"
+	if len(args) == 2 {
+		command = args[0]
+		args = args[1:]
+	}
+
 	if len(args) != 1 {
 		panic("I need a path to parse, terminating.")
 	}
 
 	dirPath := args[0]
 
-	outInfo, err := os.Stat(*outputFile)
-	if err != nil {
-		outInfo = nil
+	// Imported notes get indexed along with the rest.
+	if command == "import" {
+		if *importFrom == "" {
+			panic("I need something to import (-import-from), terminating.")
+		}
+
+		importNotes(splitList(*importFrom), dirPath, *fileExt)
+		command = "index"
+	}
+
+	// Collect the output file, along with any extra pages from an earlier run.
+	outInfos := []os.FileInfo{}
+	for page := 0; ; page++ {
+		outInfo, err := os.Stat(pageFileName(*outputFile, page))
+		if err != nil || isIndexPage(pageFileName(*outputFile, page), page) == false {
+			break
+		}
+
+		outInfos = append(outInfos, outInfo)
 	}
 
-	rootEntry := traverseDir(dirPath, *fileExt, outInfo)
+	oldPages := len(outInfos)
 
-	dumpText := rootEntry.Dump(*fileExt)
-	ioutil.WriteFile(*outputFile, []byte(dumpText), 0644)
+	// Other generated files shouldn't be indexed either.
+	for _, extraFile := range []string{*board, *people, *ics, *snippetsOut, *glossaryOut, *bibOut, *attachmentsHTML} {
+		if extraInfo, err := os.Stat(extraFile); extraFile != "" && err == nil {
+			outInfos = append(outInfos, extraInfo)
+		}
+	}
+
+	rootEntry := traverseDir(dirPath, *fileExt, outInfos, splitList(*attachmentExts), parseFormats(*formats))
+
+	// Without a vault, links and embeds are left to the usual rules.
+	var vault *ObsidianVault
+	if *obsidian {
+		vault = readObsidianVault(dirPath)
+		vault.apply(&rootEntry)
+	}
+
+	rootEntry.pin("", readPins(*pinsFile))
+	rootEntry.chooseTags(splitList(*tagSources))
+
+	detectors := secretDetectors(splitList(*scanHosts))
+	allowlist := readScanAllowlist(*scanAllow)
+
+	switch command {
+	case "index":
+		if *scanStrict {
+			findings := scanNotes(rootEntry, detectors, allowlist)
+			if len(findings) > 0 {
+				reportFindings(findings)
+				os.Exit(1)
+			}
+		}
+
+		for _, topic := range splitList(*series) {
+			updateSeries(&rootEntry, topic, *fileExt)
+		}
+		updateQueries(rootEntry, *fileExt)
+
+		opts := DumpOptions{fileExt: *fileExt, deepToc: *deepToc, toc: *toc, recent: *recent,
+			pageSize: *pageSize, outputName: filepath.Base(*outputFile), attachments: *listAttachments}
+
+		pages := rootEntry.Dump(opts)
+		for page, dumpText := range pages {
+			ioutil.WriteFile(pageFileName(*outputFile, page), []byte(dumpText), 0644)
+		}
+
+		// Clean up pages left over from an earlier, longer index.
+		for page := len(pages); page < oldPages; page++ {
+			os.Remove(pageFileName(*outputFile, page))
+		}
+
+		if *board != "" {
+			columns := buildBoard(rootEntry, *boardField, splitList(*boardColumns), *boardSort)
+			boardText := dumpBoard(*board, columns, *boardField, *fileExt)
+			ioutil.WriteFile(*board, []byte(boardText), 0644)
+		}
+
+		if *people != "" {
+			aliases := readPeopleAliases(*peopleAliases)
+			peopleText := dumpPeople(rootEntry, aliases, *fileExt)
+			ioutil.WriteFile(*people, []byte(peopleText), 0644)
+		}
+
+		if *attachmentsHTML != "" {
+			gallery := dumpAttachmentGallery(rootEntry)
+			ioutil.WriteFile(*attachmentsHTML, []byte(gallery), 0644)
+		}
+
+		if *ics != "" {
+			items := calendarItems(rootEntry, splitList(*icsFields), *fileExt)
+			ioutil.WriteFile(*ics, []byte(dumpCalendar(items, *icsBaseURL)), 0644)
+		}
+
+	case "lint":
+		severities := parseLintSeverities(*lintRules)
+		if lintNotes(rootEntry, severities) {
+			os.Exit(1)
+		}
+
+	case "scan":
+		findings := scanNotes(rootEntry, detectors, allowlist)
+		reportFindings(findings)
+		if len(findings) > 0 {
+			os.Exit(1)
+		}
+
+	case "publish":
+		publishNotes(rootEntry, *publishDir, *fileExt, *includeDepth, vault)
+
+	case "wiki":
+		publishWiki(rootEntry, *wikiDir, *fileExt, *includeDepth, vault)
+
+	case "anki":
+		cards := dumpFlashcards(rootEntry, *ankiDeck)
+		ioutil.WriteFile(*ankiOut, []byte(cards), 0644)
+
+	case "links":
+		inventory := linkInventory(rootEntry)
+		statuses := map[string]LinkStatus{}
+
+		if *linksCheck {
+			checker := newLinkChecker(*linksTimeout, *linksConcurrency, *linksRetries)
+			if *linksCache != "" {
+				checker.loadCache(*linksCache, *linksCacheTTL)
+			}
+
+			statuses = checker.checkAll(sortedURLs(inventory))
+
+			if *linksCache != "" {
+				checker.saveCache(*linksCache)
+			}
+		}
+
+		if reportLinks(inventory, statuses) {
+			os.Exit(1)
+		}
+
+	case "snippets":
+		catalog := buildSnippetCatalog(rootEntry, splitList(*snippetLangs), *fileExt)
+		jsonFile := strings.TrimSuffix(*snippetsOut, filepath.Ext(*snippetsOut)) + ".json"
+
+		ioutil.WriteFile(*snippetsOut, []byte(dumpSnippets(catalog)), 0644)
+		ioutil.WriteFile(jsonFile, []byte(dumpSnippetsJSON(catalog)), 0644)
+
+	case "glossary":
+		ioutil.WriteFile(*glossaryOut, []byte(dumpGlossary(rootEntry)), 0644)
+
+	case "bib":
+		if *bibFile == "" {
+			panic("I need a BibTeX file (-bib), terminating.")
+		}
+
+		bibText, err := ioutil.ReadFile(*bibFile)
+		if err != nil {
+			panic(err)
+		}
+
+		bibliography := dumpBibliography(rootEntry, parseBibTeX(string(bibText)))
+		ioutil.WriteFile(*bibOut, []byte(bibliography), 0644)
+
+	case "attachments":
+		if reportUnusedAttachments(rootEntry) {
+			os.Exit(1)
+		}
+
+	case "naming":
+		noteRules := parseNamingRules(*noteNaming)
+		topicRules := parseNamingRules(*topicNaming)
+		if enforceNaming(dirPath, rootEntry, *fileExt, noteRules, topicRules, *namingFix) {
+			os.Exit(1)
+		}
+
+	default:
+		panic("Unknown command " + command + ", terminating.")
+	}
 }
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Notes that aren't Markdown.  Each format knows its own file extensions, and
+// how to find the title and metadata of a note, so that Org files, notebooks
+// and the like can sit in the same tree and still get proper titles and
+// tags.  The metadata comes back as front matter, so everything downstream
+// treats it the same way.
+
+type NoteFormat interface {
+	Name() string
+	Extensions() []string
+
+	// Returns the title (or "" if there is none), the metadata, and the text
+	// to look for hashtags in.
+	Parse(content string) (string, FrontMatter, string)
+}
+
+// All known formats.  New formats only need to be added here.
+var noteFormats = []NoteFormat{
+	orgFormat{},
+	notebookFormat{},
+	asciidocFormat{},
+	rstFormat{},
+}
+
+// Turn a comma-separated list of format names into formats.
+func parseFormats(spec string) []NoteFormat {
+	formats := []NoteFormat{}
+
+	for _, name := range splitList(spec) {
+		found := false
+
+		for _, format := range noteFormats {
+			if strings.EqualFold(format.Name(), name) {
+				formats = append(formats, format)
+				found = true
+			}
+		}
+
+		if found == false {
+			panic("Unknown note format " + name + ", terminating.")
+		}
+	}
+
+	return formats
+}
+
+// Pick the format for a file by its extension, or nil if none of `formats`
+// handles it.
+func formatFor(name string, formats []NoteFormat) NoteFormat {
+	ext := filepath.Ext(name)
+
+	for _, format := range formats {
+		if containsFold(format.Extensions(), ext) {
+			return format
+		}
+	}
+
+	return nil
+}
+
+// Add a metadata value.  Tags and keywords are lists, split on `separators`.
+func addMeta(meta FrontMatter, key string, value string, separators string) {
+	key = strings.ToLower(strings.TrimSpace(key))
+
+	if key == "tags" || key == "keywords" || key == "filetags" {
+		split := func(char rune) bool { return strings.ContainsRune(separators, char) }
+		for _, tag := range strings.FieldsFunc(value, split) {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				meta["tags"] = append(meta["tags"], tag)
+			}
+		}
+		return
+	}
+
+	meta[key] = append(meta[key], strings.TrimSpace(value))
+}
+
+// Org mode: `#+TITLE:` and friends, with `#+FILETAGS: :one:two:`.
+type orgFormat struct{}
+
+var orgKeywordPattern = regexp.MustCompile(`^#\+(\w+):(.*)$`)
+
+func (orgFormat) Name() string         { return "org" }
+func (orgFormat) Extensions() []string { return []string{".org"} }
+
+func (orgFormat) Parse(content string) (string, FrontMatter, string) {
+	meta := FrontMatter{}
+	body := []string{}
+
+	for _, line := range strings.Split(content, "\n") {
+		if match := orgKeywordPattern.FindStringSubmatch(line); match != nil {
+			addMeta(meta, match[1], match[2], ": ")
+			continue
+		}
+
+		body = append(body, line)
+	}
+
+	return meta.value("title"), meta, strings.Join(body, "\n")
+}
+
+// Jupyter notebooks: the title is the first heading (or failing that, the
+// first line) of the first Markdown cell, unless the notebook metadata has
+// one.  Only Markdown cells count as text.
+type notebookFormat struct{}
+
+func (notebookFormat) Name() string         { return "ipynb" }
+func (notebookFormat) Extensions() []string { return []string{".ipynb"} }
+
+type notebookCell struct {
+	CellType string          `json:"cell_type"`
+	Source   json.RawMessage `json:"source"`
+}
+
+// Cell sources are either a string or a list of lines.
+func (cell notebookCell) text() string {
+	var lines []string
+	if err := json.Unmarshal(cell.Source, &lines); err == nil {
+		return strings.Join(lines, "")
+	}
+
+	var text string
+	json.Unmarshal(cell.Source, &text)
+	return text
+}
+
+func (notebookFormat) Parse(content string) (string, FrontMatter, string) {
+	var notebook struct {
+		Cells    []notebookCell `json:"cells"`
+		Metadata struct {
+			Title string      `json:"title"`
+			Tags  interface{} `json:"tags"`
+		} `json:"metadata"`
+	}
+
+	meta := FrontMatter{}
+
+	if err := json.Unmarshal([]byte(content), &notebook); err != nil {
+		fmt.Fprintln(os.Stderr, "Ignoring bad notebook: "+err.Error())
+		return "", meta, ""
+	}
+
+	switch tags := notebook.Metadata.Tags.(type) {
+	case string:
+		addMeta(meta, "tags", tags, ",")
+	case []interface{}:
+		for _, tag := range tags {
+			addMeta(meta, "tags", fmt.Sprint(tag), ",")
+		}
+	}
+
+	title := notebook.Metadata.Title
+	body := []string{}
+
+	for _, cell := range notebook.Cells {
+		if cell.CellType != "markdown" {
+			continue
+		}
+
+		text := cell.text()
+		body = append(body, text)
+
+		for _, line := range strings.Split(text, "\n") {
+			if title != "" {
+				break
+			}
+
+			if level, heading := parseHeading(line); level > 0 {
+				title = plainHeadingText(heading)
+			} else if strings.TrimSpace(line) != "" && len(body) == 1 {
+				title = strings.TrimSpace(line)
+			}
+		}
+	}
+
+	if title != "" {
+		meta["title"] = []string{title}
+	}
+
+	return title, meta, strings.Join(body, "\n\n")
+}
+
+// AsciiDoc: a `= Title` line, followed by a header of `:name: value`
+// attribute entries.
+type asciidocFormat struct{}
+
+var attributeEntryPattern = regexp.MustCompile(`^:(\w[\w-]*):(.*)$`)
+
+func (asciidocFormat) Name() string         { return "asciidoc" }
+func (asciidocFormat) Extensions() []string { return []string{".adoc", ".asciidoc", ".asc"} }
+
+func (asciidocFormat) Parse(content string) (string, FrontMatter, string) {
+	meta := FrontMatter{}
+	title := ""
+
+	lines := strings.Split(content, "\n")
+	i := 0
+
+	// The header is over at the first blank line after it starts.
+	for ; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], " \t\r")
+
+		if line == "" {
+			if title != "" || len(meta) > 0 {
+				break
+			}
+			continue
+		}
+
+		if strings.HasPrefix(line, "//") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "= ") && title == "" {
+			title = strings.TrimSpace(line[2:])
+		} else if match := attributeEntryPattern.FindStringSubmatch(line); match != nil {
+			addMeta(meta, match[1], match[2], ",")
+		} else if title == "" {
+			// No header at all.
+			break
+		}
+	}
+
+	if title != "" {
+		meta["title"] = []string{title}
+	}
+
+	return title, meta, strings.Join(lines[i:], "\n")
+}
+
+// reStructuredText: the first section title, with an overline and underline
+// or just an underline, and a field list of `:name: value` docinfo.
+type rstFormat struct{}
+
+var rstFieldPattern = regexp.MustCompile(`^:(\w[\w -]*):(.*)$`)
+
+func (rstFormat) Name() string         { return "rst" }
+func (rstFormat) Extensions() []string { return []string{".rst", ".rest"} }
+
+// Check whether a line is a section adornment, like `=====` or `-----`.
+func rstAdornment(line string) bool {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.ContainsRune("!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~", rune(line[0])) == false {
+		return false
+	}
+
+	return strings.Trim(line, line[:1]) == ""
+}
+
+func (rstFormat) Parse(content string) (string, FrontMatter, string) {
+	meta := FrontMatter{}
+	title := ""
+
+	lines := strings.Split(content, "\n")
+	titleEnd := -1
+
+	for i := 0; i+1 < len(lines) && title == ""; i++ {
+		text := strings.TrimSpace(lines[i])
+		if text == "" {
+			continue
+		}
+
+		if rstAdornment(lines[i]) && i+2 < len(lines) && strings.TrimSpace(lines[i+1]) != "" &&
+			strings.TrimRight(lines[i+2], " \t\r") == strings.TrimRight(lines[i], " \t\r") {
+			title = strings.TrimSpace(lines[i+1])
+			titleEnd = i + 2
+		} else if rstAdornment(lines[i]) == false && rstAdornment(lines[i+1]) &&
+			len(strings.TrimSpace(lines[i+1])) >= utf8.RuneCountInString(text) {
+			title = text
+			titleEnd = i + 1
+		} else if rstFieldPattern.MatchString(text) == false && strings.HasPrefix(text, "..") == false {
+			// Body text before any title, so there isn't one.
+			break
+		}
+	}
+
+	// Docinfo comes right after the title, or at the very top.
+	for i := titleEnd + 1; i < len(lines); i++ {
+		text := strings.TrimSpace(lines[i])
+		if text == "" && len(meta) == 0 {
+			continue
+		}
+
+		match := rstFieldPattern.FindStringSubmatch(text)
+		if match == nil {
+			break
+		}
+
+		addMeta(meta, match[1], match[2], ",")
+	}
+
+	if title != "" {
+		meta["title"] = []string{title}
+	}
+
+	return title, meta, content
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"strings"
+)
+
+// Front matter is the YAML block between `---` lines at the very top of a
+// note.  We only need a small subset of YAML: scalars, inline lists like
+// `[a, b]`, block lists of `- item` lines, and one level of nested `key:
+// value` pairs.  Every key maps to a list of strings; nested pairs are kept
+// as "key: value" strings for the caller to pick apart.
+type FrontMatter map[string][]string
+
+// First value for `key`, or "" if there isn't one.
+func (meta FrontMatter) value(key string) string {
+	if len(meta[key]) == 0 {
+		return ""
+	}
+
+	return meta[key][0]
+}
+
+func (meta FrontMatter) list(key string) []string {
+	return meta[key]
+}
+
+func (meta FrontMatter) flag(key string) bool {
+	value := strings.ToLower(meta.value(key))
+	return value == "true" || value == "yes"
+}
+
+func unquote(value string) string {
+	value = strings.TrimSpace(value)
+
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+
+	return value
+}
+
+// Split the front matter off `content`.  Returns the parsed front matter and
+// the number of lines it took up (0 if the note has none).
+func parseFrontMatter(content string) (FrontMatter, int) {
+	meta := FrontMatter{}
+	lines := strings.Split(content, "\n")
+
+	if len(lines) == 0 || strings.TrimSpace(lines[0]) != "---" {
+		return meta, 0
+	}
+
+	key := ""
+
+	for i := 1; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], " \t\r")
+		trimmed := strings.TrimSpace(line)
+
+		if line == "---" || line == "..." {
+			return meta, i + 1
+		}
+
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		// Indented lines belong to the last key.
+		if line[0] == ' ' || line[0] == '\t' {
+			if key != "" {
+				item := strings.TrimPrefix(trimmed, "- ")
+				meta[key] = append(meta[key], unquote(item))
+			}
+			continue
+		}
+
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		key = strings.TrimSpace(parts[0])
+		value := strings.TrimSpace(parts[1])
+
+		switch {
+		case value == "":
+			meta[key] = []string{}
+
+		case strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]"):
+			items := []string{}
+			for _, item := range strings.Split(value[1:len(value)-1], ",") {
+				if item = unquote(item); item != "" {
+					items = append(items, item)
+				}
+			}
+			meta[key] = items
+
+		default:
+			meta[key] = []string{unquote(value)}
+		}
+	}
+
+	// No closing line, so this wasn't front matter after all.
+	return FrontMatter{}, 0
+}
+
+// The content of a note without its front matter.
+func noteBody(content string) string {
+	_, metaLines := parseFrontMatter(content)
+	return strings.Join(strings.Split(content, "\n")[metaLines:], "\n")
+}
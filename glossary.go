@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Glossary of terms defined across all notes, either with definition lists
+//
+//	Term
+//	: What the term means.
+//
+// or with a `glossary:` map in front matter.  The same term defined
+// differently in different notes is flagged as a conflict.
+
+type Definition struct {
+	term       string
+	definition string
+	note       string
+}
+
+// Pull the definitions out of a note, which lives at `relPath`.
+func extractDefinitions(body string, meta FrontMatter, relPath string) []Definition {
+	definitions := []Definition{}
+
+	// Front matter entries come out of the parser as "term: definition".
+	for _, item := range meta.list("glossary") {
+		parts := strings.SplitN(item, ":", 2)
+		if len(parts) == 2 && strings.TrimSpace(parts[0]) != "" {
+			definition := Definition{strings.TrimSpace(parts[0]), unquote(parts[1]), relPath}
+			definitions = append(definitions, definition)
+		}
+	}
+
+	lines := strings.Split(body, "\n")
+	fenced := fencedLines(lines)
+	term := ""
+
+	for i, line := range lines {
+		if fenced[i] || strings.TrimSpace(line) == "" {
+			term = ""
+			continue
+		}
+
+		if strings.HasPrefix(line, ": ") || strings.HasPrefix(line, ":\t") {
+			if term != "" {
+				definition := Definition{term, strings.TrimSpace(line[2:]), relPath}
+				definitions = append(definitions, definition)
+			}
+			continue
+		}
+
+		// Any other line could be the term for the definitions that follow.
+		term = ""
+		if level, _ := parseHeading(line); level == 0 {
+			term = strings.TrimSpace(line)
+		}
+	}
+
+	return definitions
+}
+
+// Check whether the definitions of a term disagree, across more than one
+// note.
+func conflicting(definitions []Definition) bool {
+	normalize := func(text string) string {
+		return strings.ToLower(strings.Join(strings.Fields(text), " "))
+	}
+
+	for _, definition := range definitions[1:] {
+		if definition.note != definitions[0].note &&
+			normalize(definition.definition) != normalize(definitions[0].definition) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Render the glossary, reporting conflicts on stderr as well.
+func dumpGlossary(rootEntry Entry) string {
+	terms := map[string][]Definition{}
+
+	rootEntry.walk("", func(path string, note Note) {
+		content, err := ioutil.ReadFile(note.path)
+		if err != nil {
+			panic(err)
+		}
+
+		for _, definition := range extractDefinitions(noteBody(string(content)), note.meta, noteURL(path, note)) {
+			key := strings.ToLower(definition.term)
+			terms[key] = append(terms[key], definition)
+		}
+	})
+
+	keys := make([]string, 0, len(terms))
+	for key := range terms {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	result := "# Glossary\n"
+
+	for _, key := range keys {
+		definitions := terms[key]
+		result += "\n## " + definitions[0].term + "\n\n"
+
+		for _, definition := range definitions {
+			result += fmt.Sprintf("- %s (from [%s](%s))\n", definition.definition,
+				pathBase(definition.note), definition.note)
+		}
+
+		if conflicting(definitions) {
+			result += "\n**Conflicting definitions.**\n"
+			fmt.Fprintln(os.Stderr, "Conflicting definitions of "+definitions[0].term)
+		}
+	}
+
+	return result
+}
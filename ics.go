@@ -0,0 +1,174 @@
+package main
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Calendar export of the dates found in notes: front matter fields like
+// `due: 2026-11-01`, and `@due(2026-11-01)` markers in the body.  Each one
+// becomes an all-day event (or a timed one, for `@due(2026-11-01 14:30)`)
+// that links back to its note.
+
+var dueMarkerPattern = regexp.MustCompile(`@(due|deadline)\((\d{4}-\d{2}-\d{2})(?:[ T](\d{1,2}:\d{2}))?\)`)
+var listMarkerPattern = regexp.MustCompile(`^\s*(?:[-*+]|\d+\.)\s+(?:\[[ xX]\]\s+)?`)
+
+type CalendarItem struct {
+	summary  string
+	date     time.Time
+	timed    bool
+	path     string
+	modified time.Time
+}
+
+// Parse a date, with an optional time of day.
+func parseItemDate(date string, clock string) (time.Time, bool, error) {
+	if clock == "" {
+		parsed, err := time.Parse("2006-01-02", date)
+		return parsed, false, err
+	}
+
+	parsed, err := time.ParseInLocation("2006-01-02 15:04", date+" "+clock, time.Local)
+	return parsed, true, err
+}
+
+// Collect the dated items of a single note, which lives at `relPath`.
+func noteCalendarItems(content string, meta FrontMatter, relPath string, title string,
+	modified time.Time, fields []string) []CalendarItem {
+
+	items := []CalendarItem{}
+
+	for _, field := range fields {
+		value := meta.value(field)
+		if value == "" {
+			continue
+		}
+
+		parts := strings.Fields(value)
+		clock := ""
+		if len(parts) > 1 {
+			clock = parts[1]
+		}
+
+		date, timed, err := parseItemDate(parts[0], clock)
+		if err == nil {
+			summary := title + " (" + field + ")"
+			items = append(items, CalendarItem{summary, date, timed, relPath, modified})
+		}
+	}
+
+	lines := strings.Split(content, "\n")
+	fenced := fencedLines(lines)
+
+	for i, line := range lines {
+		if fenced[i] {
+			continue
+		}
+
+		for _, match := range dueMarkerPattern.FindAllStringSubmatch(line, -1) {
+			date, timed, err := parseItemDate(match[2], match[3])
+			if err != nil {
+				continue
+			}
+
+			// The rest of the line says what is due.
+			summary := dueMarkerPattern.ReplaceAllString(line, "")
+			summary = strings.TrimSpace(listMarkerPattern.ReplaceAllString(summary, ""))
+			if summary == "" {
+				summary = title
+			}
+
+			items = append(items, CalendarItem{summary, date, timed, relPath, modified})
+		}
+	}
+
+	return items
+}
+
+// Escape text for an iCalendar TEXT value.
+func icsEscape(text string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`).Replace(text)
+}
+
+// Fold a content line so that no line is longer than 75 bytes, without
+// splitting UTF-8 sequences.
+func icsFold(line string) string {
+	result := ""
+	limit := 75
+
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+
+		result += line[:cut] + "\r\n "
+		line = line[cut:]
+
+		// Continuation lines start with a space, which counts too.
+		limit = 74
+	}
+
+	return result + line + "\r\n"
+}
+
+// Render all items as an iCalendar file.  Event UIDs only depend on the note,
+// date and summary, so calendar apps update events instead of duplicating
+// them.  `baseURL`, if set, turns note paths into links.
+func dumpCalendar(items []CalendarItem, baseURL string) string {
+	result := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//parse-notes//EN\r\nCALSCALE:GREGORIAN\r\n"
+
+	for _, item := range items {
+		hash := sha1.Sum([]byte(item.path + "\x00" + item.date.String() + "\x00" + item.summary))
+
+		result += "BEGIN:VEVENT\r\n"
+		result += icsFold(fmt.Sprintf("UID:%x@parse-notes", hash))
+
+		// Stamp events with the note's time, so that the file only changes
+		// when the notes do.
+		result += "DTSTAMP:" + item.modified.UTC().Format("20060102T150405Z") + "\r\n"
+
+		if item.timed {
+			start := item.date.UTC()
+			result += "DTSTART:" + start.Format("20060102T150405Z") + "\r\n"
+			result += "DTEND:" + start.Add(time.Hour).Format("20060102T150405Z") + "\r\n"
+		} else {
+			result += "DTSTART;VALUE=DATE:" + item.date.Format("20060102") + "\r\n"
+			result += "DTEND;VALUE=DATE:" + item.date.AddDate(0, 0, 1).Format("20060102") + "\r\n"
+		}
+
+		result += icsFold("SUMMARY:" + icsEscape(item.summary))
+		result += icsFold("DESCRIPTION:" + icsEscape("From "+item.path))
+
+		if baseURL != "" {
+			result += icsFold("URL:" + strings.TrimSuffix(baseURL, "/") + "/" + item.path)
+		}
+
+		result += "END:VEVENT\r\n"
+	}
+
+	return result + "END:VCALENDAR\r\n"
+}
+
+// Collect the dated items from every note in the tree.
+func calendarItems(rootEntry Entry, fields []string, fileExt string) []CalendarItem {
+	items := []CalendarItem{}
+
+	rootEntry.walk("", func(path string, note Note) {
+		content, err := ioutil.ReadFile(note.path)
+		if err != nil {
+			panic(err)
+		}
+
+		body := noteBody(string(content))
+		title := note.displayName(fileExt)
+		noteItems := noteCalendarItems(body, note.meta, noteURL(path, note), title, note.timestamp, fields)
+		items = append(items, noteItems...)
+	})
+
+	return items
+}
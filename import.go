@@ -0,0 +1,588 @@
+package main
+
+import (
+	"archive/zip"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Importing notes from other tools.  Evernote exports a notebook as an ENEX
+// file, which becomes a topic of its own, and Notion exports a workspace as a
+// zip of Markdown pages, where each page with sub-pages gets a directory.
+// Either way the notes end up as Markdown with front matter, next to their
+// attachments.
+
+type ImportedNote struct {
+	title   string
+	created time.Time
+	updated time.Time
+	tags    []string
+
+	// Any other front matter, like the properties of a Notion page.
+	extra map[string]string
+	body  string
+}
+
+var unsafeNamePattern = regexp.MustCompile(`[/\\:*?"<>|\x00-\x1f]+`)
+
+// Make a title usable as a file name.
+func safeName(name string) string {
+	name = strings.TrimSpace(unsafeNamePattern.ReplaceAllString(name, "-"))
+	name = strings.TrimLeft(name, ".")
+
+	if name == "" {
+		return "Untitled"
+	}
+
+	return name
+}
+
+// Pick a path in `dir` for `name` that isn't taken yet, by numbering it.
+func uniquePath(dir string, name string) string {
+	ext := filepath.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+	path := filepath.Join(dir, name)
+
+	for i := 2; ; i++ {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return path
+		}
+
+		path = filepath.Join(dir, fmt.Sprintf("%s %d%s", stem, i, ext))
+	}
+}
+
+// Quote a front matter value, in a way that our own parser reads back.
+func yamlQuote(value string) string {
+	if strings.Contains(value, "\"") {
+		return "'" + strings.Replace(value, "'", "''", -1) + "'"
+	}
+
+	return "\"" + value + "\""
+}
+
+func (note ImportedNote) render() string {
+	result := "---\ntitle: " + yamlQuote(note.title) + "\n"
+
+	if note.created.IsZero() == false {
+		result += "created: " + note.created.Format(time.RFC3339) + "\n"
+	}
+
+	if len(note.tags) > 0 {
+		result += "tags:\n"
+		for _, tag := range note.tags {
+			result += "  - " + yamlQuote(tag) + "\n"
+		}
+	}
+
+	keys := make([]string, 0, len(note.extra))
+	for key := range note.extra {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		result += key + ": " + yamlQuote(note.extra[key]) + "\n"
+	}
+
+	return result + "---\n\n" + strings.TrimSpace(note.body) + "\n"
+}
+
+// Write a note, dating the file after the note where we can.
+func (note ImportedNote) write(path string) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		panic(err)
+	}
+
+	if err := ioutil.WriteFile(path, []byte(note.render()), 0644); err != nil {
+		panic(err)
+	}
+
+	modified := note.updated
+	if modified.IsZero() {
+		modified = note.created
+	}
+
+	if modified.IsZero() == false {
+		os.Chtimes(path, modified, modified)
+	}
+}
+
+// Link to a file in the same directory.
+func fileLink(name string) string {
+	return (&url.URL{Path: name}).EscapedPath()
+}
+
+// Evernote
+
+type enexResource struct {
+	Data     string `xml:"data"`
+	Mime     string `xml:"mime"`
+	FileName string `xml:"resource-attributes>file-name"`
+}
+
+type enexNote struct {
+	Title     string         `xml:"title"`
+	Content   string         `xml:"content"`
+	Created   string         `xml:"created"`
+	Updated   string         `xml:"updated"`
+	Tags      []string       `xml:"tag"`
+	Resources []enexResource `xml:"resource"`
+}
+
+// How an attachment shows up in the converted note.
+type enexMedia struct {
+	link  string
+	name  string
+	image bool
+}
+
+func parseEnexTime(value string) time.Time {
+	parsed, err := time.Parse("20060102T150405Z", strings.TrimSpace(value))
+	if err != nil {
+		return time.Time{}
+	}
+
+	return parsed
+}
+
+// Turn the ENML (a subset of XHTML) that Evernote keeps notes in into
+// Markdown.  Attachments are found in `media` by the hash of their data.
+func enmlToMarkdown(content string, media map[string]enexMedia) string {
+	decoder := xml.NewDecoder(strings.NewReader(content))
+	decoder.Strict = false
+	decoder.AutoClose = xml.HTMLAutoClose
+	decoder.Entity = xml.HTMLEntity
+
+	var out strings.Builder
+
+	// Start a new block, unless we're at the start of one already.
+	block := func() {
+		text := out.String()
+		if text != "" && strings.HasSuffix(text, "\n\n") == false {
+			if strings.HasSuffix(text, "\n") {
+				out.WriteString("\n")
+			} else {
+				out.WriteString("\n\n")
+			}
+		}
+	}
+
+	lists := []string{}
+	counts := []int{}
+	links := []string{}
+	inPre := false
+
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			break
+		}
+
+		switch token := token.(type) {
+		case xml.StartElement:
+			attrs := map[string]string{}
+			for _, attr := range token.Attr {
+				attrs[strings.ToLower(attr.Name.Local)] = attr.Value
+			}
+
+			switch name := strings.ToLower(token.Name.Local); name {
+			case "h1", "h2", "h3", "h4", "h5", "h6":
+				block()
+				out.WriteString(strings.Repeat("#", int(name[1]-'0')) + " ")
+			case "p", "div", "blockquote", "table":
+				if len(lists) == 0 {
+					block()
+				}
+			case "tr":
+				out.WriteString("\n")
+			case "td", "th":
+				out.WriteString(" ")
+			case "br":
+				out.WriteString("\n")
+			case "hr":
+				block()
+				out.WriteString("---\n\n")
+			case "b", "strong":
+				out.WriteString("**")
+			case "i", "em":
+				out.WriteString("*")
+			case "s", "strike", "del":
+				out.WriteString("~~")
+			case "code":
+				if inPre == false {
+					out.WriteString("`")
+				}
+			case "pre":
+				block()
+				out.WriteString("```\n")
+				inPre = true
+			case "a":
+				links = append(links, attrs["href"])
+				out.WriteString("[")
+			case "ul", "ol":
+				if len(lists) == 0 {
+					block()
+				}
+				lists = append(lists, name)
+				counts = append(counts, 0)
+			case "li":
+				if strings.HasSuffix(out.String(), "\n") == false && out.Len() > 0 {
+					out.WriteString("\n")
+				}
+
+				depth := len(lists) - 1
+				if depth < 0 {
+					depth = 0
+				}
+				out.WriteString(strings.Repeat("  ", depth))
+
+				if len(lists) > 0 && lists[depth] == "ol" {
+					counts[depth]++
+					out.WriteString(fmt.Sprintf("%d. ", counts[depth]))
+				} else {
+					out.WriteString("- ")
+				}
+			case "en-todo":
+				if out.Len() == 0 || strings.HasSuffix(out.String(), "\n") {
+					out.WriteString("- ")
+				}
+
+				if attrs["checked"] == "true" {
+					out.WriteString("[x] ")
+				} else {
+					out.WriteString("[ ] ")
+				}
+			case "en-media":
+				if item, found := media[strings.ToLower(attrs["hash"])]; found {
+					if item.image {
+						out.WriteString("!")
+					}
+					out.WriteString("[" + item.name + "](" + item.link + ")")
+				}
+			case "img":
+				out.WriteString("![" + attrs["alt"] + "](" + attrs["src"] + ")")
+			}
+
+		case xml.EndElement:
+			switch name := strings.ToLower(token.Name.Local); name {
+			case "h1", "h2", "h3", "h4", "h5", "h6", "p", "div", "blockquote", "table":
+				if len(lists) == 0 {
+					block()
+				}
+			case "b", "strong":
+				out.WriteString("**")
+			case "i", "em":
+				out.WriteString("*")
+			case "s", "strike", "del":
+				out.WriteString("~~")
+			case "code":
+				if inPre == false {
+					out.WriteString("`")
+				}
+			case "pre":
+				if strings.HasSuffix(out.String(), "\n") == false {
+					out.WriteString("\n")
+				}
+				out.WriteString("```\n\n")
+				inPre = false
+			case "a":
+				if len(links) > 0 {
+					out.WriteString("](" + links[len(links)-1] + ")")
+					links = links[:len(links)-1]
+				}
+			case "ul", "ol":
+				if len(lists) > 0 {
+					lists = lists[:len(lists)-1]
+					counts = counts[:len(counts)-1]
+				}
+				if len(lists) == 0 {
+					block()
+				}
+			}
+
+		case xml.CharData:
+			text := string(token)
+			if inPre == false {
+				text = strings.Join(strings.Fields(text), " ")
+				if text == "" {
+					break
+				}
+
+				// Keep the spaces around inline text, but not at the start of
+				// a line.
+				if strings.TrimLeftFunc(string(token), unicode.IsSpace) != string(token) &&
+					strings.HasSuffix(out.String(), "\n") == false && out.Len() > 0 {
+					text = " " + text
+				}
+				if strings.TrimRightFunc(string(token), unicode.IsSpace) != string(token) {
+					text += " "
+				}
+			}
+
+			out.WriteString(text)
+		}
+	}
+
+	lines := strings.Split(out.String(), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " ")
+	}
+
+	result := strings.Join(lines, "\n")
+	for strings.Contains(result, "\n\n\n") {
+		result = strings.Replace(result, "\n\n\n", "\n\n", -1)
+	}
+
+	return strings.TrimSpace(result)
+}
+
+// Import an ENEX file as a topic named after the notebook.
+func importEnex(source string, basePath string, fileExt string) {
+	file, err := os.Open(source)
+	if err != nil {
+		panic(err)
+	}
+	defer file.Close()
+
+	var export struct {
+		Notes []enexNote `xml:"note"`
+	}
+
+	decoder := xml.NewDecoder(file)
+	decoder.Strict = false
+	if err := decoder.Decode(&export); err != nil {
+		panic("Can't read " + source + ": " + err.Error() + ", terminating.")
+	}
+
+	notebook := safeName(strings.TrimSuffix(filepath.Base(source), filepath.Ext(source)))
+	dir := filepath.Join(basePath, notebook)
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		panic(err)
+	}
+
+	for _, enex := range export.Notes {
+		media := map[string]enexMedia{}
+
+		for _, resource := range enex.Resources {
+			data, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(resource.Data), ""))
+			if err != nil {
+				fmt.Fprintln(os.Stderr, source+": skipping bad attachment in "+enex.Title)
+				continue
+			}
+
+			hash := md5.Sum(data)
+			key := hex.EncodeToString(hash[:])
+
+			name := resource.FileName
+			if name == "" {
+				name = key
+				if exts, _ := mime.ExtensionsByType(resource.Mime); len(exts) > 0 {
+					name += exts[0]
+				}
+			}
+
+			path := uniquePath(dir, safeName(name))
+			if err := ioutil.WriteFile(path, data, 0644); err != nil {
+				panic(err)
+			}
+
+			media[key] = enexMedia{link: fileLink(filepath.Base(path)), name: filepath.Base(path),
+				image: strings.HasPrefix(resource.Mime, "image/")}
+		}
+
+		note := ImportedNote{title: enex.Title, created: parseEnexTime(enex.Created),
+			updated: parseEnexTime(enex.Updated), tags: enex.Tags}
+		note.body = enmlToMarkdown(enex.Content, media)
+		if strings.HasPrefix(note.body, "# ") == false {
+			note.body = "# " + enex.Title + "\n\n" + note.body
+		}
+
+		path := uniquePath(dir, safeName(enex.Title)+fileExt)
+		note.write(path)
+		fmt.Println("Imported " + path)
+	}
+}
+
+// Notion
+
+// Notion puts a 32-digit hex ID after every page name.
+var notionIDPattern = regexp.MustCompile(`(?: |%20)[0-9a-f]{32}`)
+var notionExportPattern = regexp.MustCompile(`^Export-[0-9a-f-]+$`)
+var notionPropertyPattern = regexp.MustCompile(`^([A-Za-z][\w ]*):\s+(.+)$`)
+
+var notionTimeLayouts = []string{"January 2, 2006 3:04 PM", "January 2, 2006", "2006-01-02", time.RFC3339}
+
+// Turn a Notion page into a note.  Pages start with their title, and
+// database pages (`inDatabase`) follow that with a block of `Property: value`
+// lines.
+func convertNotionPage(content string, inDatabase bool) ImportedNote {
+	content = notionIDPattern.ReplaceAllString(content, "")
+	lines := strings.Split(strings.Replace(content, "\r\n", "\n", -1), "\n")
+
+	note := ImportedNote{extra: map[string]string{}}
+
+	i := 0
+	for i < len(lines) && strings.TrimSpace(lines[i]) == "" {
+		i++
+	}
+
+	if i < len(lines) && strings.HasPrefix(lines[i], "# ") {
+		note.title = strings.TrimSpace(lines[i][2:])
+		i++
+	}
+
+	start := i
+	for start < len(lines) && strings.TrimSpace(lines[start]) == "" {
+		start++
+	}
+
+	end := start
+	for end < len(lines) && notionPropertyPattern.MatchString(lines[end]) {
+		end++
+	}
+
+	// Only a whole block of properties counts.  Outside a database, a single
+	// line is more likely to be a paragraph like "Note: ..." than a property.
+	whole := end == len(lines) || strings.TrimSpace(lines[end]) == ""
+	if whole && (end-start >= 2 || (end > start && inDatabase)) {
+		for _, line := range lines[start:end] {
+			match := notionPropertyPattern.FindStringSubmatch(line)
+			key := strings.Replace(strings.ToLower(strings.TrimSpace(match[1])), " ", "_", -1)
+			value := strings.TrimSpace(match[2])
+
+			switch key {
+			case "tags":
+				note.tags = splitList(value)
+			case "created", "created_time":
+				for _, layout := range notionTimeLayouts {
+					if created, err := time.Parse(layout, value); err == nil {
+						note.created = created
+						break
+					}
+				}
+			default:
+				note.extra[key] = value
+			}
+		}
+
+		lines = append(lines[:i], lines[end:]...)
+	}
+
+	note.body = strings.Join(lines, "\n")
+	if note.title != "" {
+		note.body = "# " + note.title + "\n" + strings.Join(lines[i:], "\n")
+	}
+
+	return note
+}
+
+// Import a Notion export zip, keeping its layout of pages and directories
+// but without the IDs.
+func importNotion(source string, basePath string, fileExt string) {
+	archive, err := zip.OpenReader(source)
+	if err != nil {
+		panic("Can't read " + source + ": " + err.Error() + ", terminating.")
+	}
+	defer archive.Close()
+
+	// Where each file goes, without the IDs.
+	cleanName := func(zipName string) string {
+		parts := strings.Split(zipName, "/")
+		if len(parts) > 1 && notionExportPattern.MatchString(parts[0]) {
+			parts = parts[1:]
+		}
+
+		for i, part := range parts {
+			parts[i] = safeName(notionIDPattern.ReplaceAllString(part, ""))
+		}
+
+		return filepath.Join(parts...)
+	}
+
+	// A database is exported as a CSV file, next to a directory of the same
+	// name that holds its pages.
+	databases := map[string]bool{}
+	for _, file := range archive.File {
+		if strings.HasSuffix(file.Name, ".csv") {
+			databases[strings.TrimSuffix(cleanName(file.Name), ".csv")] = true
+		}
+	}
+
+	for _, file := range archive.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+
+		name := cleanName(file.Name)
+		if strings.HasSuffix(name, ".md") {
+			name = strings.TrimSuffix(name, ".md") + fileExt
+		}
+
+		path := filepath.Join(basePath, name)
+		if _, err := os.Stat(path); err == nil {
+			fmt.Fprintln(os.Stderr, "Not importing "+path+", it already exists")
+			continue
+		}
+
+		reader, err := file.Open()
+		if err != nil {
+			panic(err)
+		}
+
+		data, err := ioutil.ReadAll(io.LimitReader(reader, 1<<30))
+		reader.Close()
+		if err != nil {
+			panic(err)
+		}
+
+		if strings.HasSuffix(file.Name, ".md") {
+			note := convertNotionPage(string(data), databases[filepath.Dir(name)])
+			if note.title == "" {
+				note.title = strings.TrimSuffix(filepath.Base(path), fileExt)
+			}
+
+			note.write(path)
+			fmt.Println("Imported " + path)
+			continue
+		}
+
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			panic(err)
+		}
+
+		if err := ioutil.WriteFile(path, data, 0644); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// Import each of `sources` into the tree at `basePath`.
+func importNotes(sources []string, basePath string, fileExt string) {
+	for _, source := range sources {
+		switch strings.ToLower(filepath.Ext(source)) {
+		case ".enex":
+			importEnex(source, basePath, fileExt)
+		case ".zip":
+			importNotion(source, basePath, fileExt)
+		default:
+			panic("Don't know how to import " + source + ", terminating.")
+		}
+	}
+}
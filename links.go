@@ -0,0 +1,284 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Inventory of external links across all notes, with an optional checker
+// that asks each server whether the link still works.
+
+var externalURLPattern = regexp.MustCompile("https?://[^\\s<>()\\[\\]\"'`]+(?:\\([^\\s()]*\\)[^\\s<>()\\[\\]\"'`]*)*")
+
+type LinkUse struct {
+	count int
+	notes []string
+}
+
+// Find every http(s) URL in the body of a note, outside code blocks.
+func extractURLs(body string) []string {
+	urls := []string{}
+
+	lines := strings.Split(body, "\n")
+	fenced := fencedLines(lines)
+
+	for i, line := range lines {
+		if fenced[i] {
+			continue
+		}
+
+		for _, url := range externalURLPattern.FindAllString(line, -1) {
+			urls = append(urls, strings.TrimRight(url, ".,;:!?*_"))
+		}
+	}
+
+	return urls
+}
+
+// Count the uses of every external URL, and which notes use it.
+func linkInventory(rootEntry Entry) map[string]*LinkUse {
+	inventory := map[string]*LinkUse{}
+
+	rootEntry.walk("", func(path string, note Note) {
+		content, err := ioutil.ReadFile(note.path)
+		if err != nil {
+			panic(err)
+		}
+
+		relPath := noteURL(path, note)
+
+		for _, url := range extractURLs(noteBody(string(content))) {
+			use, found := inventory[url]
+			if found == false {
+				use = &LinkUse{}
+				inventory[url] = use
+			}
+
+			use.count++
+			if len(use.notes) == 0 || use.notes[len(use.notes)-1] != relPath {
+				use.notes = append(use.notes, relPath)
+			}
+		}
+	})
+
+	return inventory
+}
+
+// URLs in the inventory, most used first.
+func sortedURLs(inventory map[string]*LinkUse) []string {
+	urls := make([]string, 0, len(inventory))
+	for url := range inventory {
+		urls = append(urls, url)
+	}
+
+	sort.Slice(urls, func(i int, j int) bool {
+		if inventory[urls[i]].count != inventory[urls[j]].count {
+			return inventory[urls[i]].count > inventory[urls[j]].count
+		}
+		return urls[i] < urls[j]
+	})
+
+	return urls
+}
+
+// What we last heard about a URL.  These get cached between runs, so the
+// fields are exported for encoding/json.
+type LinkStatus struct {
+	Status   int       `json:"status"`
+	Location string    `json:"location,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	Checked  time.Time `json:"checked"`
+}
+
+func (status LinkStatus) dead() bool {
+	return status.Error != "" || status.Status >= 400
+}
+
+func (status LinkStatus) redirected() bool {
+	return status.Status >= 300 && status.Status < 400
+}
+
+type LinkChecker struct {
+	client      *http.Client
+	concurrency int
+	retries     int
+
+	// Results younger than `ttl` are taken from the cache instead of being
+	// checked again.
+	cache map[string]LinkStatus
+	ttl   time.Duration
+	mutex sync.Mutex
+}
+
+// Build a checker that doesn't follow redirects, so that they can be
+// reported.
+func newLinkChecker(timeout time.Duration, concurrency int, retries int) *LinkChecker {
+	client := &http.Client{
+		Timeout: timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	return &LinkChecker{client: client, concurrency: concurrency, retries: retries,
+		cache: map[string]LinkStatus{}}
+}
+
+func (checker *LinkChecker) loadCache(path string, ttl time.Duration) {
+	checker.ttl = ttl
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return
+	}
+
+	if err := json.Unmarshal(content, &checker.cache); err != nil {
+		fmt.Fprintln(os.Stderr, "Ignoring bad link cache "+path+": "+err.Error())
+	}
+}
+
+func (checker *LinkChecker) saveCache(path string) {
+	content, err := json.MarshalIndent(checker.cache, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+
+	ioutil.WriteFile(path, content, 0644)
+}
+
+// Make one request.  Some servers don't handle HEAD, so fall back to GET.
+func (checker *LinkChecker) fetch(url string) LinkStatus {
+	status := LinkStatus{Checked: time.Now()}
+
+	for _, method := range []string{"HEAD", "GET"} {
+		req, err := http.NewRequest(method, url, nil)
+		if err != nil {
+			status.Error = err.Error()
+			return status
+		}
+
+		req.Header.Set("User-Agent", "parse-notes link checker")
+
+		resp, err := checker.client.Do(req)
+		if err != nil {
+			status.Error = err.Error()
+			continue
+		}
+		resp.Body.Close()
+
+		status.Status = resp.StatusCode
+		status.Location = resp.Header.Get("Location")
+		status.Error = ""
+
+		if method == "HEAD" && (resp.StatusCode == 405 || resp.StatusCode == 403 || resp.StatusCode == 501) {
+			continue
+		}
+		break
+	}
+
+	return status
+}
+
+// Check a single URL, retrying failures that might be temporary.
+func (checker *LinkChecker) check(url string) LinkStatus {
+	checker.mutex.Lock()
+	cached, found := checker.cache[url]
+	checker.mutex.Unlock()
+
+	if found && time.Since(cached.Checked) < checker.ttl {
+		return cached
+	}
+
+	status := checker.fetch(url)
+
+	for attempt := 1; attempt <= checker.retries; attempt++ {
+		if status.Error == "" && status.Status != 429 && status.Status < 500 {
+			break
+		}
+
+		time.Sleep(time.Duration(attempt) * 500 * time.Millisecond)
+		status = checker.fetch(url)
+	}
+
+	checker.mutex.Lock()
+	checker.cache[url] = status
+	checker.mutex.Unlock()
+
+	return status
+}
+
+// Check all `urls`, at most `concurrency` at a time.
+func (checker *LinkChecker) checkAll(urls []string) map[string]LinkStatus {
+	results := map[string]LinkStatus{}
+
+	var mutex sync.Mutex
+	var group sync.WaitGroup
+	queue := make(chan string)
+
+	for worker := 0; worker < checker.concurrency; worker++ {
+		group.Add(1)
+
+		go func() {
+			defer group.Done()
+
+			for url := range queue {
+				status := checker.check(url)
+
+				mutex.Lock()
+				results[url] = status
+				mutex.Unlock()
+			}
+		}()
+	}
+
+	for _, url := range urls {
+		queue <- url
+	}
+
+	close(queue)
+	group.Wait()
+
+	return results
+}
+
+// Print the inventory, along with the checker's verdict on each link if
+// there is one.  Returns true if any link is dead.
+func reportLinks(inventory map[string]*LinkUse, statuses map[string]LinkStatus) bool {
+	dead := false
+
+	for _, url := range sortedURLs(inventory) {
+		use := inventory[url]
+		line := fmt.Sprintf("%s (%d): %s", url, use.count, strings.Join(use.notes, ", "))
+
+		if status, checked := statuses[url]; checked {
+			switch {
+			case status.Error != "":
+				line = "DEAD " + line + " [" + status.Error + "]"
+			case status.dead():
+				line = fmt.Sprintf("DEAD %s [%d]", line, status.Status)
+			case status.redirected():
+				line = fmt.Sprintf("MOVED %s [%d -> %s]", line, status.Status, status.Location)
+			default:
+				line = "OK " + line
+			}
+
+			dead = dead || status.dead()
+		}
+
+		fmt.Println(line)
+	}
+
+	return dead
+}
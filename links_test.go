@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCheckFallsBackToGet(t *testing.T) {
+	gets := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "HEAD" {
+			w.WriteHeader(405)
+			return
+		}
+		gets++
+	}))
+	defer server.Close()
+
+	status := newLinkChecker(time.Second, 1, 0).check(server.URL)
+	if status.Status != 200 || gets != 1 {
+		t.Errorf("got status %d after %d GETs, want 200 after 1", status.Status, gets)
+	}
+}
+
+func TestCheckReportsRedirects(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/moved", 301)
+	}))
+	defer server.Close()
+
+	status := newLinkChecker(time.Second, 1, 0).check(server.URL)
+	if status.redirected() == false || status.dead() || status.Location != "/moved" {
+		t.Errorf("got %+v, want a 301 to /moved", status)
+	}
+}
+
+func TestCheckRetriesServerErrors(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.WriteHeader(503)
+		}
+	}))
+	defer server.Close()
+
+	status := newLinkChecker(time.Second, 1, 2).check(server.URL)
+	if status.Status != 200 || requests != 2 {
+		t.Errorf("got status %d after %d requests, want 200 after 2", status.Status, requests)
+	}
+}
+
+func TestCheckUsesFreshCache(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer server.Close()
+
+	checker := newLinkChecker(time.Second, 1, 0)
+	checker.ttl = time.Hour
+	checker.cache[server.URL] = LinkStatus{Status: 404, Checked: time.Now()}
+
+	status := checker.check(server.URL)
+	if status.Status != 404 || requests != 0 {
+		t.Errorf("got status %d after %d requests, want the cached 404", status.Status, requests)
+	}
+}
+
+func TestCheckAllLimitsConcurrency(t *testing.T) {
+	var mutex sync.Mutex
+	active := 0
+	most := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		active++
+		if active > most {
+			most = active
+		}
+		mutex.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+
+		mutex.Lock()
+		active--
+		mutex.Unlock()
+	}))
+	defer server.Close()
+
+	urls := []string{}
+	for i := 0; i < 12; i++ {
+		urls = append(urls, fmt.Sprintf("%s/%d", server.URL, i))
+	}
+
+	results := newLinkChecker(time.Second, 3, 0).checkAll(urls)
+	if len(results) != len(urls) {
+		t.Errorf("got %d results, want %d", len(results), len(urls))
+	}
+
+	if most > 3 {
+		t.Errorf("%d requests ran at once, want at most 3", most)
+	}
+}
@@ -0,0 +1,359 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Structural lint rules for notes.  Each rule looks at one note at a time and
+// reports issues; the severity of every rule can be overridden from the
+// command line, and a note can switch rules off for itself with a comment
+// like `<!-- lint-disable trailing-whitespace -->` (or `<!-- lint-disable -->`
+// to switch off everything).
+
+type Severity int
+
+const (
+	SeverityOff Severity = iota
+	SeverityWarning
+	SeverityError
+)
+
+func (severity Severity) String() string {
+	switch severity {
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	}
+
+	return "off"
+}
+
+type LintIssue struct {
+	line    int
+	message string
+
+	// Issues that may well be on purpose are only worth a warning, whatever
+	// the severity of the rule.
+	warning bool
+}
+
+// A single note, split up the way that most rules want to look at it.
+type LintDoc struct {
+	lines    []string
+	headings []Heading
+}
+
+type LintRule interface {
+	Name() string
+	DefaultSeverity() Severity
+	Check(doc LintDoc) []LintIssue
+}
+
+// All known rules, in the order that they are run.  New rules only need to be
+// added here.
+var lintRules = []LintRule{
+	multipleH1Rule{},
+	headingIncrementRule{},
+	emptySectionRule{},
+	trailingWhitespaceRule{},
+	fenceMismatchRule{},
+}
+
+type multipleH1Rule struct{}
+
+func (multipleH1Rule) Name() string              { return "multiple-h1" }
+func (multipleH1Rule) DefaultSeverity() Severity { return SeverityError }
+
+func (multipleH1Rule) Check(doc LintDoc) []LintIssue {
+	issues := []LintIssue{}
+	seen := false
+
+	for _, heading := range doc.headings {
+		if heading.level != 1 {
+			continue
+		}
+
+		if seen {
+			issues = append(issues, LintIssue{heading.line, "more than one top-level heading", false})
+		}
+		seen = true
+	}
+
+	return issues
+}
+
+type headingIncrementRule struct{}
+
+func (headingIncrementRule) Name() string              { return "heading-increment" }
+func (headingIncrementRule) DefaultSeverity() Severity { return SeverityWarning }
+
+func (headingIncrementRule) Check(doc LintDoc) []LintIssue {
+	issues := []LintIssue{}
+
+	for i := 1; i < len(doc.headings); i++ {
+		prev, heading := doc.headings[i-1], doc.headings[i]
+
+		if heading.level > prev.level+1 {
+			message := fmt.Sprintf("heading level jumps from %d to %d", prev.level, heading.level)
+			issues = append(issues, LintIssue{heading.line, message, false})
+		}
+	}
+
+	return issues
+}
+
+type emptySectionRule struct{}
+
+func (emptySectionRule) Name() string              { return "empty-section" }
+func (emptySectionRule) DefaultSeverity() Severity { return SeverityWarning }
+
+func (emptySectionRule) Check(doc LintDoc) []LintIssue {
+	issues := []LintIssue{}
+
+	for i, heading := range doc.headings {
+		// The section ends at the next heading, or at the end of the note.
+		end := len(doc.lines)
+		nested := false
+
+		if i+1 < len(doc.headings) {
+			end = doc.headings[i+1].line
+			nested = doc.headings[i+1].level > heading.level
+		}
+
+		// A section whose only content is its subsections isn't empty.
+		if nested {
+			continue
+		}
+
+		empty := true
+		for _, line := range doc.lines[heading.line+1 : end] {
+			if strings.TrimSpace(line) != "" {
+				empty = false
+				break
+			}
+		}
+
+		if empty {
+			issues = append(issues, LintIssue{heading.line, "section \"" + heading.text + "\" is empty", false})
+		}
+	}
+
+	return issues
+}
+
+type trailingWhitespaceRule struct{}
+
+func (trailingWhitespaceRule) Name() string              { return "trailing-whitespace" }
+func (trailingWhitespaceRule) DefaultSeverity() Severity { return SeverityWarning }
+
+func (trailingWhitespaceRule) Check(doc LintDoc) []LintIssue {
+	issues := []LintIssue{}
+
+	for i, line := range doc.lines {
+		line = strings.TrimSuffix(line, "\r")
+
+		if strings.TrimRight(line, " \t") != line {
+			issues = append(issues, LintIssue{i, "trailing whitespace", false})
+		}
+	}
+
+	return issues
+}
+
+type fenceMismatchRule struct{}
+
+func (fenceMismatchRule) Name() string              { return "fence-mismatch" }
+func (fenceMismatchRule) DefaultSeverity() Severity { return SeverityError }
+
+func (fenceMismatchRule) Check(doc LintDoc) []LintIssue {
+	issues := []LintIssue{}
+
+	open := byte(0)
+	openLength := 0
+	openLine := 0
+
+	for i, line := range doc.lines {
+		char, length, info := fenceMarker(line)
+		if char == 0 {
+			continue
+		}
+
+		if open == 0 {
+			open, openLength, openLine = char, length, i
+			continue
+		}
+
+		if char == open && length >= openLength && info == "" {
+			open = 0
+			continue
+		}
+
+		// Any other fence is part of the block, like an example of a shorter
+		// fence inside a longer one.  A bare one was probably meant to close
+		// it, though.
+		if info == "" {
+			message := fmt.Sprintf("%s doesn't close the code block opened with %s on line %d",
+				strings.Repeat(string(char), length), strings.Repeat(string(open), openLength), openLine+1)
+			issues = append(issues, LintIssue{i, message, true})
+		}
+	}
+
+	if open != 0 {
+		issues = append(issues, LintIssue{openLine, "code block is never closed", false})
+	}
+
+	return issues
+}
+
+// Parse a spec like `trailing-whitespace=off,heading-increment=error` into a
+// severity for every known rule.
+func parseLintSeverities(spec string) map[string]Severity {
+	severities := map[string]Severity{}
+
+	for _, rule := range lintRules {
+		severities[rule.Name()] = rule.DefaultSeverity()
+	}
+
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		parts := strings.SplitN(item, "=", 2)
+		if len(parts) != 2 {
+			panic("Bad lint rule setting " + item + ", terminating.")
+		}
+
+		name := strings.TrimSpace(parts[0])
+		if _, known := severities[name]; known == false {
+			panic("Unknown lint rule " + name + ", terminating.")
+		}
+
+		switch strings.TrimSpace(parts[1]) {
+		case "off":
+			severities[name] = SeverityOff
+		case "warning":
+			severities[name] = SeverityWarning
+		case "error":
+			severities[name] = SeverityError
+		default:
+			panic("Unknown lint severity " + parts[1] + ", terminating.")
+		}
+	}
+
+	return severities
+}
+
+var lintDisablePattern = regexp.MustCompile(`<!--\s*lint-disable((?:\s+[\w-]+)*)\s*-->`)
+
+// Find the rules that a note has switched off for itself.  The second result
+// is true when the note switches off every rule.
+func lintDisabled(content string) (map[string]bool, bool) {
+	disabled := map[string]bool{}
+
+	for _, match := range lintDisablePattern.FindAllStringSubmatch(content, -1) {
+		names := strings.Fields(match[1])
+		if len(names) == 0 {
+			return nil, true
+		}
+
+		for _, name := range names {
+			disabled[name] = true
+		}
+	}
+
+	return disabled, false
+}
+
+// Run every enabled rule over a single note.  Returns the formatted reports,
+// and whether any of them were errors.
+func lintNote(path string, content string, severities map[string]Severity) ([]string, bool) {
+	disabled, all := lintDisabled(content)
+	if all {
+		return nil, false
+	}
+
+	lines := strings.Split(content, "\n")
+
+	// Front matter isn't Markdown, so blank it out, keeping the line numbers.
+	_, metaLines := parseFrontMatter(content)
+	for i := 0; i < metaLines; i++ {
+		lines[i] = ""
+	}
+
+	doc := LintDoc{lines: lines, headings: parseHeadings(lines)}
+
+	type report struct {
+		line int
+		text string
+	}
+
+	reports := []report{}
+	failed := false
+
+	for _, rule := range lintRules {
+		severity := severities[rule.Name()]
+		if severity == SeverityOff || disabled[rule.Name()] {
+			continue
+		}
+
+		for _, issue := range rule.Check(doc) {
+			issueSeverity := severity
+			if issue.warning && issueSeverity > SeverityWarning {
+				issueSeverity = SeverityWarning
+			}
+
+			text := fmt.Sprintf("%s:%d: %s: %s [%s]",
+				path, issue.line+1, issueSeverity, issue.message, rule.Name())
+			reports = append(reports, report{issue.line, text})
+
+			if issueSeverity == SeverityError {
+				failed = true
+			}
+		}
+	}
+
+	sort.SliceStable(reports, func(i int, j int) bool {
+		return reports[i].line < reports[j].line
+	})
+
+	result := make([]string, 0, len(reports))
+	for _, report := range reports {
+		result = append(result, report.text)
+	}
+
+	return result, failed
+}
+
+// Lint every note in the tree, printing issues as we go.  Returns true if any
+// issue had error severity.
+func lintNotes(rootEntry Entry, severities map[string]Severity) bool {
+	failed := false
+
+	rootEntry.walk("", func(path string, note Note) {
+		// The rules only make sense for Markdown.
+		if note.format != nil {
+			return
+		}
+
+		content, err := ioutil.ReadFile(note.path)
+		if err != nil {
+			panic(err)
+		}
+
+		reports, noteFailed := lintNote(note.path, string(content), severities)
+		for _, report := range reports {
+			fmt.Println(report)
+		}
+
+		failed = failed || noteFailed
+	})
+
+	return failed
+}
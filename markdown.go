@@ -0,0 +1,182 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Helpers for picking apart the block structure of a Markdown note.  These
+// only understand as much Markdown as we need: ATX headings, fenced code
+// blocks and inline links.
+
+type Heading struct {
+	level int
+	text  string
+	line  int
+}
+
+// Check whether `line` opens or closes a fenced code block.  Returns the fence
+// character (0 if this isn't a fence), the length of the marker, and any info
+// string that follows it.
+func fenceMarker(line string) (byte, int, string) {
+	trimmed := strings.TrimLeft(line, " ")
+	if len(line)-len(trimmed) > 3 || len(trimmed) < 3 {
+		return 0, 0, ""
+	}
+
+	char := trimmed[0]
+	if char != '`' && char != '~' {
+		return 0, 0, ""
+	}
+
+	length := 0
+	for length < len(trimmed) && trimmed[length] == char {
+		length++
+	}
+
+	if length < 3 {
+		return 0, 0, ""
+	}
+
+	return char, length, strings.TrimSpace(trimmed[length:])
+}
+
+// Report, for each line, whether it belongs to a fenced code block.  The fence
+// lines themselves count as part of the block.
+func fencedLines(lines []string) []bool {
+	inside := make([]bool, len(lines))
+
+	open := byte(0)
+	openLength := 0
+
+	for i, line := range lines {
+		char, length, info := fenceMarker(line)
+
+		if open == 0 {
+			if char != 0 {
+				open, openLength = char, length
+				inside[i] = true
+			}
+			continue
+		}
+
+		inside[i] = true
+		if char == open && length >= openLength && info == "" {
+			open = 0
+		}
+	}
+
+	return inside
+}
+
+// Parse `line` as an ATX heading.  Returns a level of 0 if it isn't one.
+func parseHeading(line string) (int, string) {
+	trimmed := strings.TrimLeft(line, " ")
+	if len(line)-len(trimmed) > 3 {
+		return 0, ""
+	}
+
+	level := 0
+	for level < len(trimmed) && trimmed[level] == '#' {
+		level++
+	}
+
+	if level == 0 || level > 6 {
+		return 0, ""
+	}
+
+	rest := trimmed[level:]
+	if rest != "" && rest[0] != ' ' && rest[0] != '\t' {
+		return 0, ""
+	}
+
+	// Drop the optional closing sequence of hashes.
+	text := strings.TrimSpace(rest)
+	closing := strings.TrimRight(text, "#")
+	if closing == "" || strings.HasSuffix(closing, " ") {
+		text = strings.TrimSpace(closing)
+	}
+
+	return level, text
+}
+
+// Collect all headings in `lines`, skipping anything inside code blocks.
+func parseHeadings(lines []string) []Heading {
+	headings := []Heading{}
+	fenced := fencedLines(lines)
+
+	for i, line := range lines {
+		if fenced[i] {
+			continue
+		}
+
+		level, text := parseHeading(line)
+		if level > 0 {
+			headings = append(headings, Heading{level: level, text: text, line: i})
+		}
+	}
+
+	return headings
+}
+
+// Matches inline links and images, capturing the link target.
+var markdownLinkPattern = regexp.MustCompile(`\[[^\]]*\]\(([^)\s]+)(?:\s+"[^"]*")?\)`)
+
+// Check whether a link target points outside the notes tree (or is only an
+// anchor within the same note).
+func isExternalLink(target string) bool {
+	if strings.HasPrefix(target, "#") || strings.HasPrefix(target, "mailto:") {
+		return true
+	}
+
+	return strings.Contains(target, "://")
+}
+
+var slugPunctuationPattern = regexp.MustCompile(`[^\p{L}\p{N}\p{M}\p{Pc} -]`)
+
+// Reduce the inline markup in heading text to what GitHub renders, since
+// that is what anchors get built from.
+func plainHeadingText(text string) string {
+	text = markdownLinkPattern.ReplaceAllStringFunc(text, func(link string) string {
+		link = strings.TrimPrefix(link, "!")
+		return link[1:strings.Index(link, "](")]
+	})
+
+	return strings.NewReplacer("`", "", "**", "", "__", "").Replace(text)
+}
+
+// Build the anchor that GitHub gives a heading, before deduplication.
+func githubSlug(text string) string {
+	slug := strings.ToLower(plainHeadingText(text))
+	slug = slugPunctuationPattern.ReplaceAllString(slug, "")
+
+	return strings.Replace(slug, " ", "-", -1)
+}
+
+// Hands out GitHub anchors for the headings of one document, numbering
+// repeats the way GitHub does (`intro`, `intro-1`, `intro-2`, ...).
+type Slugger struct {
+	occurrences map[string]int
+}
+
+func newSlugger() *Slugger {
+	return &Slugger{occurrences: map[string]int{}}
+}
+
+func (slugger *Slugger) slug(text string) string {
+	base := githubSlug(text)
+	result := base
+
+	for {
+		if _, taken := slugger.occurrences[result]; taken == false {
+			break
+		}
+
+		slugger.occurrences[base]++
+		result = base + "-" + strconv.Itoa(slugger.occurrences[base])
+	}
+
+	slugger.occurrences[result] = 0
+	return result
+}
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Naming policy for notes and topic directories.  Rules are checked against
+// the name without its extension (the "stem"), and each rule knows how to fix
+// the names that break it.
+
+type NamingRule struct {
+	name  string
+	check func(stem string) bool
+	fix   func(stem string, timestamp time.Time) string
+}
+
+var datePrefixPattern = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}-`)
+var kebabCasePattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+var nonKebabPattern = regexp.MustCompile(`[^a-z0-9]+`)
+
+var namingRules = map[string]NamingRule{
+	"lowercase": {
+		name: "lowercase",
+		check: func(stem string) bool {
+			return stem == strings.ToLower(stem)
+		},
+		fix: func(stem string, timestamp time.Time) string {
+			return strings.ToLower(stem)
+		},
+	},
+	"no-spaces": {
+		name: "no-spaces",
+		check: func(stem string) bool {
+			return strings.Contains(stem, " ") == false
+		},
+		fix: func(stem string, timestamp time.Time) string {
+			return strings.Join(strings.Fields(stem), "-")
+		},
+	},
+	"kebab-case": {
+		name: "kebab-case",
+		check: func(stem string) bool {
+			return kebabCasePattern.MatchString(stem)
+		},
+		fix: func(stem string, timestamp time.Time) string {
+			kebab := nonKebabPattern.ReplaceAllString(strings.ToLower(stem), "-")
+			return strings.Trim(kebab, "-")
+		},
+	},
+	"date-prefix": {
+		name: "date-prefix",
+		check: func(stem string) bool {
+			return datePrefixPattern.MatchString(stem)
+		},
+		fix: func(stem string, timestamp time.Time) string {
+			return timestamp.Format("2006-01-02") + "-" + stem
+		},
+	},
+}
+
+// Turn a comma-separated list of rule names into rules.
+func parseNamingRules(spec string) []NamingRule {
+	rules := []NamingRule{}
+
+	for _, name := range splitList(spec) {
+		rule, known := namingRules[name]
+		if known == false {
+			panic("Unknown naming rule " + name + ", terminating.")
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules
+}
+
+type NamingViolation struct {
+	path    string
+	rules   []string
+	newName string
+}
+
+// Check `stem` against `rules`, and work out a name that satisfies them all.
+// The suggested name is empty if the fixes don't manage that.
+func checkName(stem string, suffix string, rules []NamingRule, timestamp time.Time) ([]string, string) {
+	broken := []string{}
+	fixed := stem
+
+	for _, rule := range rules {
+		if rule.check(stem) == false {
+			broken = append(broken, rule.name)
+		}
+
+		if rule.check(fixed) == false {
+			fixed = rule.fix(fixed, timestamp)
+		}
+	}
+
+	if len(broken) == 0 {
+		return broken, ""
+	}
+
+	for _, rule := range rules {
+		if fixed == "" || rule.check(fixed) == false {
+			return broken, ""
+		}
+	}
+
+	return broken, fixed + suffix
+}
+
+// Check every note and topic directory under `entry`, whose files live in
+// `basePath`.
+func checkNaming(basePath string, entry Entry, fileExt string, noteRules []NamingRule,
+	topicRules []NamingRule) []NamingViolation {
+
+	violations := []NamingViolation{}
+
+	notes := entry.notes
+	sort.Stable(notes)
+
+	for _, note := range notes {
+		if note.duplicate {
+			continue
+		}
+
+		stem := note.stem(fileExt)
+
+		broken, newName := checkName(stem, note.name[len(stem):], noteRules, note.timestamp)
+		if len(broken) > 0 {
+			violations = append(violations, NamingViolation{note.path, broken, newName})
+		}
+	}
+
+	keys := make([]string, 0, len(entry.subTopics))
+
+	for key := range entry.subTopics {
+		keys = append(keys, string(key))
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		fullPath := basePath + string(os.PathSeparator) + key
+
+		// Virtual topics (from front matter) have no directory to check.
+		info, err := os.Stat(fullPath)
+
+		if len(topicRules) > 0 && err == nil {
+			broken, newName := checkName(key, "", topicRules, info.ModTime())
+			if len(broken) > 0 {
+				violations = append(violations, NamingViolation{fullPath, broken, newName})
+			}
+		}
+
+		subEntry := entry.subTopics[Topic(key)]
+		subViolations := checkNaming(fullPath, *subEntry, fileExt, noteRules, topicRules)
+		violations = append(violations, subViolations...)
+	}
+
+	return violations
+}
+
+// Work out where `path` ends up once every renamed file and directory between
+// `basePath` and it has moved.
+func renamedPath(basePath string, path string, renames map[string]string) string {
+	rel, err := filepath.Rel(basePath, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return path
+	}
+
+	oldPath := filepath.Clean(basePath)
+	newPath := oldPath
+
+	for _, part := range strings.Split(rel, string(os.PathSeparator)) {
+		oldPath = filepath.Join(oldPath, part)
+
+		if newName, found := renames[oldPath]; found {
+			part = newName
+		}
+
+		newPath = filepath.Join(newPath, part)
+	}
+
+	return newPath
+}
+
+// Rewrite links in a note that lives at `notePath` so that they still point
+// to the right place after the renames.
+func rewriteLinks(basePath string, notePath string, content string, renames map[string]string) string {
+	oldDir := filepath.Dir(filepath.Clean(notePath))
+	newDir := filepath.Dir(renamedPath(basePath, filepath.Clean(notePath), renames))
+
+	return markdownLinkPattern.ReplaceAllStringFunc(content, func(link string) string {
+		match := markdownLinkPattern.FindStringSubmatchIndex(link)
+		target := link[match[2]:match[3]]
+
+		if isExternalLink(target) || filepath.IsAbs(target) {
+			return link
+		}
+
+		fragment := ""
+		if index := strings.Index(target, "#"); index >= 0 {
+			target, fragment = target[:index], target[index:]
+		}
+
+		unescaped, err := url.PathUnescape(target)
+		if err != nil {
+			return link
+		}
+
+		oldTarget := filepath.Join(oldDir, filepath.FromSlash(unescaped))
+		newTarget := renamedPath(basePath, oldTarget, renames)
+
+		if newTarget == oldTarget && newDir == oldDir {
+			return link
+		}
+
+		rel, err := filepath.Rel(newDir, newTarget)
+		if err != nil {
+			return link
+		}
+
+		return link[:match[2]] + filepath.ToSlash(rel) + fragment + link[match[3]:]
+	})
+}
+
+// Rename everything in `violations` that has a fix, updating links in all
+// notes to match.  Returns true if some violations couldn't be fixed.
+func fixNaming(basePath string, rootEntry Entry, violations []NamingViolation) bool {
+	basePath = filepath.Clean(basePath)
+	renames := map[string]string{}
+	targets := map[string]bool{}
+	unfixed := false
+
+	for _, violation := range violations {
+		if violation.newName == "" {
+			unfixed = true
+			continue
+		}
+
+		oldPath := filepath.Clean(violation.path)
+		newPath := filepath.Join(filepath.Dir(oldPath), violation.newName)
+
+		_, err := os.Stat(newPath)
+		if targets[newPath] || err == nil {
+			fmt.Println(violation.path + ": not renaming, " + violation.newName + " already exists")
+			unfixed = true
+			continue
+		}
+
+		renames[oldPath] = violation.newName
+		targets[newPath] = true
+	}
+
+	// Fix up the links before anything moves, while the old paths are valid.
+	contents := map[string]string{}
+
+	rootEntry.walk("", func(path string, note Note) {
+		content, err := ioutil.ReadFile(note.path)
+		if err != nil {
+			panic(err)
+		}
+
+		newContent := rewriteLinks(basePath, note.path, string(content), renames)
+		if newContent != string(content) {
+			contents[renamedPath(basePath, filepath.Clean(note.path), renames)] = newContent
+		}
+	})
+
+	// Rename the deepest paths first, so that their parents haven't moved yet.
+	oldPaths := make([]string, 0, len(renames))
+
+	for oldPath := range renames {
+		oldPaths = append(oldPaths, oldPath)
+	}
+
+	sort.Slice(oldPaths, func(i int, j int) bool {
+		depthI := strings.Count(oldPaths[i], string(os.PathSeparator))
+		depthJ := strings.Count(oldPaths[j], string(os.PathSeparator))
+
+		if depthI != depthJ {
+			return depthI > depthJ
+		}
+		return oldPaths[i] < oldPaths[j]
+	})
+
+	for _, oldPath := range oldPaths {
+		newPath := filepath.Join(filepath.Dir(oldPath), renames[oldPath])
+
+		if err := os.Rename(oldPath, newPath); err != nil {
+			panic(err)
+		}
+
+		fmt.Println("renamed " + oldPath + " to " + newPath)
+	}
+
+	for path, content := range contents {
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			panic(err)
+		}
+	}
+
+	return unfixed
+}
+
+// Report every naming violation, and fix them if asked to.  Returns true if
+// there were violations left unfixed.
+func enforceNaming(basePath string, rootEntry Entry, fileExt string, noteRules []NamingRule,
+	topicRules []NamingRule, fix bool) bool {
+
+	violations := checkNaming(basePath, rootEntry, fileExt, noteRules, topicRules)
+
+	for _, violation := range violations {
+		report := violation.path + ": breaks " + strings.Join(violation.rules, ", ")
+		if violation.newName != "" {
+			report += " (rename to " + violation.newName + ")"
+		}
+
+		fmt.Println(report)
+	}
+
+	if fix {
+		return fixNaming(basePath, rootEntry, violations)
+	}
+
+	return len(violations) > 0
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	pathpkg "path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Obsidian vault support.  Obsidian keeps its settings in `.obsidian/` at the
+// root of the vault, which tell us which folders are excluded, where
+// attachments go and how links are written.  Notes link to each other with
+// `[[note#Heading|label]]`, embed with `![[note]]`, and can refer to a single
+// block with `[[note#^id]]`, where the block ends with ` ^id`.
+
+var obsidianLinkPattern = regexp.MustCompile(`(!?)\[\[([^\]|#]*)(?:#([^\]|]*))?(?:\|([^\]]*))?\]\]`)
+var blockIDPattern = regexp.MustCompile(`(?:^|\s)\^([A-Za-z0-9-]+)\s*$`)
+
+type ObsidianVault struct {
+	// Paths that Obsidian excludes, as prefixes or /regexps/, relative to the
+	// root of the vault.
+	ignoreFilters []string
+
+	// Where attachments go: "" for the root of the vault, a path relative to
+	// the root, or one starting with "./" for a folder next to each note.
+	attachmentFolder string
+
+	// How links are written: "shortest" (just the name, when it is unique),
+	// "relative" (to the note) or "absolute" (from the root).
+	linkFormat string
+}
+
+// Read the settings of the vault at `basePath`.  Settings that aren't there
+// keep Obsidian's defaults.
+func readObsidianVault(basePath string) *ObsidianVault {
+	vault := &ObsidianVault{linkFormat: "shortest"}
+
+	var app struct {
+		UserIgnoreFilters    []string `json:"userIgnoreFilters"`
+		AttachmentFolderPath string   `json:"attachmentFolderPath"`
+		NewLinkFormat        string   `json:"newLinkFormat"`
+	}
+
+	configDir := filepath.Join(basePath, ".obsidian")
+	if _, err := os.Stat(configDir); err != nil {
+		fmt.Fprintln(os.Stderr, "No .obsidian settings in "+basePath+", using the defaults")
+		return vault
+	}
+
+	if content, err := ioutil.ReadFile(filepath.Join(configDir, "app.json")); err == nil {
+		if err := json.Unmarshal(content, &app); err != nil {
+			fmt.Fprintln(os.Stderr, "Ignoring bad Obsidian settings: "+err.Error())
+		}
+	}
+
+	vault.ignoreFilters = app.UserIgnoreFilters
+	if app.NewLinkFormat != "" {
+		vault.linkFormat = app.NewLinkFormat
+	}
+
+	vault.attachmentFolder = strings.TrimSuffix(app.AttachmentFolderPath, "/")
+	if vault.attachmentFolder != "." && strings.HasPrefix(vault.attachmentFolder, "./") == false {
+		vault.attachmentFolder = strings.Trim(vault.attachmentFolder, "/")
+	}
+
+	// Templates are only there to be copied, so they're vault internals too.
+	var templates struct {
+		Folder string `json:"folder"`
+	}
+
+	if content, err := ioutil.ReadFile(filepath.Join(configDir, "templates.json")); err == nil {
+		if json.Unmarshal(content, &templates) == nil && strings.Trim(templates.Folder, "/") != "" {
+			vault.ignoreFilters = append(vault.ignoreFilters, strings.Trim(templates.Folder, "/")+"/")
+		}
+	}
+
+	return vault
+}
+
+// Check whether Obsidian excludes the file or folder at `relPath`.
+func (vault *ObsidianVault) ignored(relPath string) bool {
+	for _, filter := range vault.ignoreFilters {
+		if len(filter) > 2 && strings.HasPrefix(filter, "/") && strings.HasSuffix(filter, "/") {
+			pattern, err := regexp.Compile(filter[1 : len(filter)-1])
+			if err == nil && pattern.MatchString(relPath) {
+				return true
+			}
+			continue
+		}
+
+		filter = strings.TrimPrefix(filter, "/")
+		if relPath == strings.TrimSuffix(filter, "/") || strings.HasPrefix(relPath, filter) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Drop everything that the vault excludes from the topic at `path`.
+func (vault *ObsidianVault) prune(entry *Entry, path string) {
+	join := func(name string) string {
+		if path == "" {
+			return name
+		}
+		return path + "/" + name
+	}
+
+	notes := Notes{}
+	for _, note := range entry.notes {
+		if vault.ignored(noteURL(path, note)) == false {
+			notes = append(notes, note)
+		}
+	}
+	entry.notes = notes
+
+	attachments := Notes{}
+	for _, attachment := range entry.attachments {
+		if vault.ignored(noteURL(path, attachment)) == false {
+			attachments = append(attachments, attachment)
+		}
+	}
+	entry.attachments = attachments
+
+	for key, subEntry := range entry.subTopics {
+		if vault.ignored(join(string(key))) {
+			delete(entry.subTopics, key)
+			continue
+		}
+
+		vault.prune(subEntry, join(string(key)))
+	}
+}
+
+// Attachment folders aren't topics in Obsidian, so their files are listed
+// with the topic they belong to.  Folders that hold notes as well are left
+// alone.
+func (vault *ObsidianVault) foldAttachments(entry *Entry, path string) {
+	fold := func(owner *Entry, ownerPath string, folder string) {
+		subEntry, found := owner.subTopics[Topic(folder)]
+		if found == false || strings.Contains(folder, "/") {
+			return
+		}
+
+		folderPath := folder
+		if ownerPath != "" {
+			folderPath = ownerPath + "/" + folder
+		}
+
+		for _, attachment := range subEntry.attachments {
+			attachment.link = noteURL(folderPath, attachment)
+			owner.attachments = append(owner.attachments, attachment)
+		}
+
+		subEntry.attachments = Notes{}
+		if len(subEntry.notes) == 0 && len(subEntry.subTopics) == 0 {
+			delete(owner.subTopics, Topic(folder))
+		}
+	}
+
+	switch {
+	case vault.attachmentFolder == "" || vault.attachmentFolder == ".":
+		return
+
+	case strings.HasPrefix(vault.attachmentFolder, "./"):
+		// A folder next to every note.
+		for key, subEntry := range entry.subTopics {
+			subPath := string(key)
+			if path != "" {
+				subPath = path + "/" + subPath
+			}
+			vault.foldAttachments(subEntry, subPath)
+		}
+
+		fold(entry, path, strings.TrimPrefix(vault.attachmentFolder, "./"))
+
+	default:
+		// One folder for the whole vault.
+		parent := pathpkg.Dir(vault.attachmentFolder)
+		if parent == "." {
+			parent = ""
+		}
+
+		if owner := entry.lookup(parent); owner != nil {
+			fold(owner, parent, pathpkg.Base(vault.attachmentFolder))
+		}
+	}
+}
+
+// Bring the tree in line with what Obsidian shows.
+func (vault *ObsidianVault) apply(rootEntry *Entry) {
+	vault.prune(rootEntry, "")
+	vault.foldAttachments(rootEntry, "")
+}
+
+// Cut the block with the given ID out of `lines`.  The ID either ends the
+// block's last line, or sits on a line of its own after the block.  List
+// items are blocks of their own.
+func extractBlock(lines []string, id string) ([]string, bool) {
+	fenced := fencedLines(lines)
+
+	for i, line := range lines {
+		match := blockIDPattern.FindStringSubmatch(line)
+		if fenced[i] || match == nil || match[1] != id {
+			continue
+		}
+
+		end := i
+		text := strings.TrimRight(blockIDPattern.ReplaceAllString(line, ""), " ")
+
+		if strings.TrimSpace(text) == "" {
+			// The ID refers to the block above.
+			end = i - 1
+			for end >= 0 && strings.TrimSpace(lines[end]) == "" {
+				end--
+			}
+			if end < 0 {
+				return nil, false
+			}
+			text = lines[end]
+		}
+
+		trimmed := strings.TrimSpace(text)
+		if listItemPattern.MatchString(trimmed) {
+			return []string{trimmed}, true
+		}
+
+		start := end
+		for start > 0 && strings.TrimSpace(lines[start-1]) != "" {
+			start--
+		}
+
+		block := append([]string{}, lines[start:end]...)
+		return append(block, text), true
+	}
+
+	return nil, false
+}
+
+var listItemPattern = regexp.MustCompile(`^(?:[-*+]|\d+[.)])\s`)
+
+// Drop the ` ^id` markers from the end of blocks, which Obsidian hides.
+func stripBlockIDs(lines []string) []string {
+	fenced := fencedLines(lines)
+	result := make([]string, len(lines))
+
+	for i, line := range lines {
+		result[i] = line
+		if fenced[i] == false && blockIDPattern.MatchString(line) {
+			result[i] = strings.TrimRight(blockIDPattern.ReplaceAllString(line, ""), " ")
+		}
+	}
+
+	return result
+}
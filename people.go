@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// The people index lists every note that mentions someone, either as an
+// `@name` in the body or in an `attendees:` front matter list.  An alias file
+// merges different spellings of the same person, with lines like
+//
+//	alice: alice.smith, asmith, Alice Smith
+
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w.@/])@([A-Za-z][\w.-]*\w|[A-Za-z])`)
+var codeSpanPattern = regexp.MustCompile("`[^`]*`")
+
+// Read an alias file into a map from every spelling (lowercased) to the name
+// it should be listed under.
+func readPeopleAliases(path string) map[string]string {
+	aliases := map[string]string{}
+	if path == "" {
+		return aliases
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		panic(err)
+	}
+
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.SplitN(line, ":", 2)
+		name := strings.TrimPrefix(strings.TrimSpace(parts[0]), "@")
+		aliases[strings.ToLower(name)] = name
+
+		if len(parts) == 2 {
+			for _, alias := range splitList(parts[1]) {
+				aliases[strings.ToLower(strings.TrimPrefix(alias, "@"))] = name
+			}
+		}
+	}
+
+	return aliases
+}
+
+// Everyone mentioned in a note, by their canonical names.  Names without an
+// alias are matched ignoring case, and keep the spelling they first had.
+func notePeople(content string, meta FrontMatter, aliases map[string]string) []string {
+	people := []string{}
+	found := map[string]bool{}
+
+	add := func(name string) {
+		name = strings.TrimPrefix(strings.TrimSpace(name), "@")
+		if alias, known := aliases[strings.ToLower(name)]; known {
+			name = alias
+		}
+
+		if name != "" && found[strings.ToLower(name)] == false {
+			found[strings.ToLower(name)] = true
+			people = append(people, name)
+		}
+	}
+
+	for _, attendee := range meta.list("attendees") {
+		add(attendee)
+	}
+
+	lines := strings.Split(content, "\n")
+	fenced := fencedLines(lines)
+
+	for i, line := range lines {
+		if fenced[i] {
+			continue
+		}
+
+		// Citations like [@knuth1984] and markers like @due(2024-05-01)
+		// aren't people.
+		line = codeSpanPattern.ReplaceAllString(line, "")
+		line = stripCitations(line)
+		line = dueMarkerPattern.ReplaceAllString(line, "")
+
+		for _, match := range mentionPattern.FindAllStringSubmatch(line, -1) {
+			add(match[1])
+		}
+	}
+
+	return people
+}
+
+// Render the people index.  Each person gets a section listing the notes
+// they appear in, grouped by topic.
+func dumpPeople(rootEntry Entry, aliases map[string]string, fileExt string) string {
+	type mention struct {
+		topic string
+		line  string
+	}
+
+	// Mentions by lowercased name, and the name that each is listed under.
+	mentions := map[string][]mention{}
+	names := map[string]string{}
+
+	rootEntry.walk("", func(path string, note Note) {
+		content, err := ioutil.ReadFile(note.path)
+		if err != nil {
+			panic(err)
+		}
+
+		body := noteBody(string(content))
+		name := note.displayName(fileExt)
+		line := fmt.Sprintf("[%s](%s)", name, noteURL(path, note))
+
+		for _, person := range notePeople(body, note.meta, aliases) {
+			key := strings.ToLower(person)
+			if _, seen := names[key]; seen == false {
+				names[key] = person
+			}
+
+			mentions[key] = append(mentions[key], mention{path, line})
+		}
+	})
+
+	people := []string{}
+	for key := range mentions {
+		people = append(people, key)
+	}
+
+	sort.Strings(people)
+
+	result := "# People\n"
+
+	for _, person := range people {
+		result += "\n## " + names[person] + "\n"
+
+		// Notes come in index order, so each topic's notes are together.
+		topic := "\x00"
+		for _, mention := range mentions[person] {
+			if mention.topic != topic {
+				topic = mention.topic
+
+				label := topic
+				if label == "" {
+					label = "(top level)"
+				}
+				result += "- " + label + "\n"
+			}
+
+			result += "  - " + mention.line + "\n"
+		}
+	}
+
+	return result
+}
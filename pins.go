@@ -0,0 +1,109 @@
+package main
+
+import (
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+// Pinned notes are marked with `pinned: true` in their front matter, or listed
+// in a pins file.  Either way they can be given an order, with `pin_order: N`
+// or by their position in the pins file.
+
+// Read a pins file, which lists one note per line (relative to the notes
+// directory).  Returns the pin order of each note.
+func readPins(path string) map[string]int {
+	pins := map[string]int{}
+	if path == "" {
+		return pins
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		panic(err)
+	}
+
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		pins[line] = len(pins) + 1
+	}
+
+	return pins
+}
+
+// Mark the notes listed in `pins` as pinned.  An order from front matter wins
+// over the order in the pins file.
+func (entry Entry) pin(path string, pins map[string]int) {
+	for i, note := range entry.notes {
+		order, found := pins[noteURL(path, note)]
+		if found == false {
+			continue
+		}
+
+		entry.notes[i].pinned = true
+		if note.pinOrder == 0 {
+			entry.notes[i].pinOrder = order
+		}
+	}
+
+	for key, subEntry := range entry.subTopics {
+		subPath := string(key)
+		if path != "" {
+			subPath = path + "/" + subPath
+		}
+
+		subEntry.pin(subPath, pins)
+	}
+}
+
+// Move pinned notes to the front of `notes`, ordered notes first.  Everything
+// else keeps its place.
+func sortPinned(notes Notes) {
+	sort.SliceStable(notes, func(i int, j int) bool {
+		a, b := notes[i], notes[j]
+
+		if a.pinned != b.pinned {
+			return a.pinned
+		}
+		if a.pinned == false || (a.pinOrder > 0) != (b.pinOrder > 0) {
+			return a.pinOrder > 0
+		}
+		return a.pinOrder < b.pinOrder
+	})
+}
+
+// Render the list of all pinned notes that goes at the top of the index.
+func (entry Entry) dumpPinned(opts DumpOptions) string {
+	pinned := Notes{}
+	urls := map[string]string{}
+
+	entry.walk("", func(path string, note Note) {
+		if note.pinned {
+			pinned = append(pinned, note)
+			urls[note.path] = noteURL(path, note)
+		}
+	})
+
+	if len(pinned) == 0 {
+		return ""
+	}
+
+	sortPinned(pinned)
+
+	result := indexHeading(2, "Pinned")
+	for _, note := range pinned {
+		result += noteLine(note, urls[note.path], "  ", opts)
+	}
+
+	// Without something in between, the notes at the root would join the
+	// list of pinned notes.
+	if len(entry.notes) > 0 {
+		result += "\n<!-- end of pinned notes -->\n"
+	}
+
+	return result + "\n"
+}
@@ -0,0 +1,344 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	pathpkg "path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Query blocks let a note list other notes.  A fenced block like
+//
+//	```notes-query
+//	tag:postmortem topic:ops -status:draft sort:date desc limit:10
+//	```
+//
+// is followed by the matching notes, between marker comments that get
+// rewritten on every run.  Terms are `key:value` filters (prefix with `-` to
+// negate), and the special `sort:` and `limit:` settings.  The filter keys
+// are `tag`, `topic` (the topic and everything under it), `name` (a
+// substring), `after` and `before` (dates like 2026-01-31), and otherwise any
+// front matter field.
+
+const queryInfo = "notes-query"
+const queryStart = "<!-- notes-query:start -->"
+const queryEnd = "<!-- notes-query:end -->"
+
+type QueryFilter struct {
+	key    string
+	value  string
+	negate bool
+}
+
+type NoteQuery struct {
+	filters    []QueryFilter
+	sortKey    string
+	descending bool
+	limit      int
+}
+
+type QueryResult struct {
+	path string
+	note Note
+}
+
+// Every topic that the note is listed under: the directory it lives in
+// (unless it is only listed elsewhere), and its `topics:` list.
+func (result QueryResult) topics() []string {
+	topics := []string{}
+
+	if result.note.meta.flag("topics_only") == false {
+		dir := pathpkg.Dir(noteURL(result.path, result.note))
+		if dir == "." {
+			dir = ""
+		}
+		topics = append(topics, dir)
+	}
+
+	for _, topic := range result.note.meta.list("topics") {
+		topics = append(topics, strings.Trim(topic, "/"))
+	}
+
+	return topics
+}
+
+// Split a query into terms, keeping quoted values (`status:"in review"`)
+// together.
+func queryTerms(text string) []string {
+	terms := []string{}
+	term := ""
+	quoted := false
+
+	for _, char := range text {
+		switch {
+		case char == '"':
+			quoted = !quoted
+		case (char == ' ' || char == '\t' || char == '\n') && quoted == false:
+			if term != "" {
+				terms = append(terms, term)
+			}
+			term = ""
+		default:
+			term += string(char)
+		}
+	}
+
+	if term != "" {
+		terms = append(terms, term)
+	}
+
+	return terms
+}
+
+func parseQuery(text string) (NoteQuery, error) {
+	query := NoteQuery{sortKey: "name"}
+	terms := queryTerms(text)
+
+	for i := 0; i < len(terms); i++ {
+		term := terms[i]
+
+		parts := strings.SplitN(term, ":", 2)
+		if len(parts) != 2 || parts[1] == "" {
+			return query, fmt.Errorf("expected key:value, found %q", term)
+		}
+
+		key, value := strings.ToLower(parts[0]), parts[1]
+
+		switch key {
+		case "sort":
+			query.sortKey = strings.ToLower(value)
+
+			// The direction is an optional separate word.
+			if i+1 < len(terms) && (terms[i+1] == "asc" || terms[i+1] == "desc") {
+				query.descending = terms[i+1] == "desc"
+				i++
+			}
+
+		case "limit":
+			limit, err := strconv.Atoi(value)
+			if err != nil || limit < 0 {
+				return query, fmt.Errorf("bad limit %q", value)
+			}
+			query.limit = limit
+
+		case "after", "before", "-after", "-before":
+			if _, err := time.Parse("2006-01-02", value); err != nil {
+				return query, fmt.Errorf("bad date %q", value)
+			}
+			fallthrough
+
+		default:
+			filter := QueryFilter{key: strings.TrimPrefix(key, "-"), value: value}
+			filter.negate = strings.HasPrefix(key, "-")
+			query.filters = append(query.filters, filter)
+		}
+	}
+
+	return query, nil
+}
+
+// The date of a note: its `date` front matter if it has one, and otherwise
+// when it was last modified.
+func noteDate(note Note) time.Time {
+	if date, err := time.Parse("2006-01-02", note.meta.value("date")); err == nil {
+		return date
+	}
+
+	return note.timestamp
+}
+
+func containsFold(values []string, value string) bool {
+	for _, item := range values {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (filter QueryFilter) matches(result QueryResult) bool {
+	note := result.note
+	matched := false
+
+	switch filter.key {
+	case "tag":
+		matched = containsFold(note.tags(), filter.value)
+
+	case "topic":
+		topic := strings.Trim(filter.value, "/")
+		for _, listed := range result.topics() {
+			matched = matched || listed == topic || strings.HasPrefix(listed, topic+"/")
+		}
+
+	case "name":
+		matched = strings.Contains(strings.ToLower(note.name), strings.ToLower(filter.value))
+
+	case "after", "before":
+		date, _ := time.Parse("2006-01-02", filter.value)
+		if filter.key == "after" {
+			matched = noteDate(note).After(date)
+		} else {
+			matched = noteDate(note).Before(date)
+		}
+
+	default:
+		matched = containsFold(note.meta.list(filter.key), filter.value)
+	}
+
+	return matched != filter.negate
+}
+
+// Run `query` against `candidates`, which come in index order.
+func (query NoteQuery) run(candidates []QueryResult) []QueryResult {
+	results := []QueryResult{}
+
+	for _, candidate := range candidates {
+		matched := true
+		for _, filter := range query.filters {
+			matched = matched && filter.matches(candidate)
+		}
+
+		if matched {
+			results = append(results, candidate)
+		}
+	}
+
+	sort.SliceStable(results, func(i int, j int) bool {
+		a, b := results[i].note, results[j].note
+		if query.descending {
+			a, b = b, a
+		}
+
+		switch query.sortKey {
+		case "date":
+			return noteDate(a).Before(noteDate(b))
+		case "name":
+			return a.name < b.name
+		}
+		return a.meta.value(query.sortKey) < b.meta.value(query.sortKey)
+	})
+
+	if query.limit > 0 && len(results) > query.limit {
+		results = results[:query.limit]
+	}
+
+	return results
+}
+
+// Render the results of `text` as a list of links, relative to `notePath`
+// (which is relative to the root).
+func expandQuery(text string, notePath string, candidates []QueryResult, fileExt string) string {
+	query, err := parseQuery(text)
+	if err != nil {
+		return "- Bad query: " + err.Error() + "\n"
+	}
+
+	result := ""
+	noteDir := pathpkg.Dir(notePath)
+
+	for _, match := range query.run(candidates) {
+		url := noteURL(match.path, match.note)
+		if url == notePath {
+			continue
+		}
+
+		// Both paths are relative to the root, so count the steps up from
+		// this note's directory to there.
+		if noteDir != "." {
+			url = strings.Repeat("../", strings.Count(noteDir, "/")+1) + url
+		}
+
+		name := match.note.displayName(fileExt)
+		date := noteDate(match.note).Format("02 Jan 2006")
+		result += fmt.Sprintf("- [%s](%s) [%s]\n", name, url, date)
+	}
+
+	if result == "" {
+		result = "- No matching notes.\n"
+	}
+
+	return result
+}
+
+// Rewrite the results after every query block in `content`.
+func expandQueries(content string, notePath string, candidates []QueryResult, fileExt string) string {
+	lines := strings.Split(content, "\n")
+	result := []string{}
+
+	for i := 0; i < len(lines); i++ {
+		result = append(result, lines[i])
+
+		char, length, info := fenceMarker(lines[i])
+		if char == 0 || info != queryInfo {
+			continue
+		}
+
+		// Collect the query, up to the closing fence.
+		query := ""
+		for i+1 < len(lines) {
+			i++
+			result = append(result, lines[i])
+
+			closeChar, closeLength, closeInfo := fenceMarker(lines[i])
+			if closeChar == char && closeLength >= length && closeInfo == "" {
+				break
+			}
+			query += lines[i] + "\n"
+		}
+
+		// Drop the old results, if there are any.
+		if i+1 < len(lines) && strings.TrimSpace(lines[i+1]) == queryStart {
+			for end := i + 2; end < len(lines); end++ {
+				if strings.TrimSpace(lines[end]) == queryEnd {
+					i = end
+					break
+				}
+			}
+		}
+
+		expanded := expandQuery(query, notePath, candidates, fileExt)
+		result = append(result, queryStart)
+		result = append(result, strings.Split(strings.TrimSuffix(expanded, "\n"), "\n")...)
+		result = append(result, queryEnd)
+	}
+
+	return strings.Join(result, "\n")
+}
+
+// Expand the query blocks in every note that has them.  Notes without query
+// blocks, or whose results haven't changed, are left alone.
+func updateQueries(rootEntry Entry, fileExt string) {
+	candidates := []QueryResult{}
+
+	rootEntry.walk("", func(path string, note Note) {
+		candidates = append(candidates, QueryResult{path, note})
+	})
+
+	for _, candidate := range candidates {
+		if candidate.note.format != nil {
+			continue
+		}
+
+		content, err := ioutil.ReadFile(candidate.note.path)
+		if err != nil {
+			panic(err)
+		}
+
+		if strings.Contains(string(content), queryInfo) == false {
+			continue
+		}
+
+		notePath := noteURL(candidate.path, candidate.note)
+		newContent := expandQueries(string(content), notePath, candidates, fileExt)
+
+		if newContent != string(content) {
+			if err := ioutil.WriteFile(candidate.note.path, []byte(newContent), 0644); err != nil {
+				panic(err)
+			}
+		}
+	}
+}
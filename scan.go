@@ -0,0 +1,262 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Scanner for secrets and other data that shouldn't leave the building.  Each
+// detector looks at a single line at a time and returns the suspicious text
+// it found there.
+
+type SecretDetector struct {
+	name string
+	find func(line string) []string
+}
+
+type SecretFinding struct {
+	path   string
+	line   int
+	kind   string
+	secret string
+}
+
+var awsKeyPattern = regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)
+var awsSecretPattern = regexp.MustCompile(`(?i)aws_?secret_?(?:access_?)?key\s*[:=]\s*["']?([A-Za-z0-9/+=]{40})`)
+var privateKeyPattern = regexp.MustCompile(`-----BEGIN (?:[A-Z0-9]+ )*PRIVATE KEY( BLOCK)?-----`)
+var tokenPattern = regexp.MustCompile(`[A-Za-z0-9+/=_\-]{20,}`)
+var hexPattern = regexp.MustCompile(`^[0-9a-fA-F]+$`)
+
+// Shannon entropy of `text`, in bits per character.
+func entropy(text string) float64 {
+	counts := map[rune]int{}
+	for _, char := range text {
+		counts[char]++
+	}
+
+	result := 0.0
+	for _, count := range counts {
+		p := float64(count) / float64(len(text))
+		result -= p * math.Log2(p)
+	}
+
+	return result
+}
+
+// Check whether `token` is just words and numbers strung together, like the
+// name of a note or a path to one.
+func wordsToken(token string) bool {
+	parts := strings.FieldsFunc(token, func(char rune) bool {
+		return (char >= 'a' && char <= 'z' || char >= 'A' && char <= 'Z' || char >= '0' && char <= '9') == false
+	})
+	if len(parts) == 0 {
+		return false
+	}
+
+	for _, part := range parts {
+		if wordPattern.MatchString(part) == false {
+			return false
+		}
+	}
+
+	return true
+}
+
+var wordPattern = regexp.MustCompile(`^(?:[A-Za-z][a-z]*|[0-9]+|[a-z]+[0-9]+|[A-Z]+)$`)
+
+// Keep the parts of a URL that can hold a secret: the user info, the path
+// segments that aren't words, and the query values and fragment.  The scheme
+// and host never do.
+func urlSecretParts(link string) string {
+	parsed, err := url.Parse(link)
+	if err != nil {
+		return link
+	}
+
+	parts := []string{}
+
+	if parsed.User != nil {
+		parts = append(parts, parsed.User.Username())
+		if password, set := parsed.User.Password(); set {
+			parts = append(parts, password)
+		}
+	}
+
+	segments := []string{}
+	for _, segment := range strings.Split(parsed.Path, "/") {
+		if segment != "" && wordsToken(segment) == false {
+			segments = append(segments, segment)
+		}
+	}
+	parts = append(parts, strings.Join(segments, "/"))
+
+	for _, pair := range strings.Split(parsed.RawQuery, "&") {
+		value := pair[strings.Index(pair, "=")+1:]
+		if unescaped, err := url.QueryUnescape(value); err == nil {
+			value = unescaped
+		}
+		parts = append(parts, value)
+	}
+
+	return strings.Join(append(parts, parsed.Fragment), " ")
+}
+
+// Look for long runs of mixed-case letters and digits that are too random to
+// be words.  Plain hex (commit hashes and the like) and names made of words
+// are left alone, and so are the parts of URLs that can't be secret.
+func findHighEntropy(line string) []string {
+	found := []string{}
+
+	line = urlPattern.ReplaceAllStringFunc(line, urlSecretParts)
+
+	for _, token := range tokenPattern.FindAllString(line, -1) {
+		if hexPattern.MatchString(token) || wordsToken(token) {
+			continue
+		}
+
+		if strings.ContainsAny(token, "0123456789") == false ||
+			strings.ContainsAny(token, "abcdefghijklmnopqrstuvwxyz") == false ||
+			strings.ContainsAny(token, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") == false {
+			continue
+		}
+
+		// Longer tokens have room for more entropy, so they need more of it.
+		threshold := 4.0
+		if len(token) >= 32 {
+			threshold = 4.5
+		}
+
+		if entropy(token) >= threshold {
+			found = append(found, token)
+		}
+	}
+
+	return found
+}
+
+// Build the list of detectors, including one for each internal host name (or
+// domain) in `hosts`.
+func secretDetectors(hosts []string) []SecretDetector {
+	detectors := []SecretDetector{
+		{"aws-access-key", func(line string) []string {
+			return awsKeyPattern.FindAllString(line, -1)
+		}},
+		{"aws-secret-key", func(line string) []string {
+			found := []string{}
+			for _, match := range awsSecretPattern.FindAllStringSubmatch(line, -1) {
+				found = append(found, match[1])
+			}
+			return found
+		}},
+		{"private-key", func(line string) []string {
+			return privateKeyPattern.FindAllString(line, -1)
+		}},
+		{"high-entropy-token", findHighEntropy},
+	}
+
+	for _, host := range hosts {
+		pattern := regexp.MustCompile(`(?i)\b(?:[a-z0-9-]+\.)*` + regexp.QuoteMeta(host) + `\b`)
+
+		detectors = append(detectors, SecretDetector{"internal-host", func(line string) []string {
+			return pattern.FindAllString(line, -1)
+		}})
+	}
+
+	return detectors
+}
+
+// Allowlisted secrets and notes, read from a file with one entry per line.
+// Entries of the form `note:<path>` skip that note (relative to the notes
+// directory) altogether; anything else is a literal value to ignore.
+type ScanAllowlist struct {
+	values map[string]bool
+	notes  map[string]bool
+}
+
+func readScanAllowlist(path string) ScanAllowlist {
+	allowlist := ScanAllowlist{values: map[string]bool{}, notes: map[string]bool{}}
+	if path == "" {
+		return allowlist
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		panic(err)
+	}
+
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "note:") {
+			allowlist.notes[strings.TrimPrefix(line, "note:")] = true
+		} else {
+			allowlist.values[line] = true
+		}
+	}
+
+	return allowlist
+}
+
+// Hide most of a secret, so that reports don't leak it any further.
+func redact(secret string) string {
+	if len(secret) <= 8 {
+		return strings.Repeat("*", len(secret))
+	}
+
+	return secret[:4] + strings.Repeat("*", len(secret)-6) + secret[len(secret)-2:]
+}
+
+// Scan every note in the tree, returning all findings that aren't allowed.
+func scanNotes(rootEntry Entry, detectors []SecretDetector, allowlist ScanAllowlist) []SecretFinding {
+	findings := []SecretFinding{}
+
+	rootEntry.walk("", func(path string, note Note) {
+		if allowlist.notes[noteURL(path, note)] {
+			return
+		}
+
+		content, err := ioutil.ReadFile(note.path)
+		if err != nil {
+			panic(err)
+		}
+
+		for i, line := range strings.Split(string(content), "\n") {
+			// Report each value once, even if several detectors catch it.
+			seen := map[string]bool{}
+
+			for _, detector := range detectors {
+				for _, secret := range detector.find(line) {
+					if allowlist.values[secret] || seen[secret] {
+						continue
+					}
+					seen[secret] = true
+
+					finding := SecretFinding{note.path, i, detector.name, secret}
+					findings = append(findings, finding)
+				}
+			}
+		}
+	})
+
+	return findings
+}
+
+func reportFindings(findings []SecretFinding) {
+	for _, finding := range findings {
+		// Host names and key headers aren't secret in themselves, so there's
+		// no need to hide them.
+		secret := finding.secret
+		if finding.kind != "internal-host" && finding.kind != "private-key" {
+			secret = redact(secret)
+		}
+
+		fmt.Printf("%s:%d: %s: %s\n", finding.path, finding.line+1, finding.kind, secret)
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+// Notes in a series topic get previous/next links, in the order that the
+// index lists them.  The links live between marker comments at the top and
+// bottom of each note, and get rewritten on every run.
+
+const seriesStart = "<!-- series-nav -->"
+const seriesEnd = "<!-- /series-nav -->"
+
+// Replace the navigation blocks in `content` with `nav`, adding blocks at the
+// top (after any front matter) and bottom if the note has none yet.
+func withSeriesNav(content string, nav string) string {
+	block := seriesStart + "\n" + nav + "\n" + seriesEnd
+
+	result := ""
+	rest := content
+	replaced := false
+
+	for {
+		start := strings.Index(rest, seriesStart)
+		if start < 0 {
+			break
+		}
+
+		end := strings.Index(rest[start:], seriesEnd)
+		if end < 0 {
+			break
+		}
+
+		result += rest[:start] + block
+		rest = rest[start+end+len(seriesEnd):]
+		replaced = true
+	}
+
+	if replaced {
+		return result + rest
+	}
+
+	_, metaLines := parseFrontMatter(content)
+	lines := strings.Split(content, "\n")
+
+	head := strings.Join(lines[:metaLines], "\n")
+	body := strings.Join(lines[metaLines:], "\n")
+	if metaLines > 0 {
+		head += "\n"
+	}
+
+	body = strings.TrimRight(body, "\n")
+	return head + block + "\n\n" + body + "\n\n" + block + "\n"
+}
+
+// Render the navigation block for the note at `index` in `notes`.
+func seriesNav(notes Notes, index int, fileExt string) string {
+	links := []string{}
+
+	if index > 0 {
+		prev := notes[index-1]
+		name := prev.displayName(fileExt)
+		links = append(links, "[← Previous: "+name+"]("+prev.name+")")
+	}
+
+	if index+1 < len(notes) {
+		next := notes[index+1]
+		name := next.displayName(fileExt)
+		links = append(links, "[Next: "+name+" →]("+next.name+")")
+	}
+
+	return strings.Join(links, " | ")
+}
+
+// Rewrite the navigation blocks of every note directly in `topic`.  Notes
+// that are already up to date are left alone, so their timestamps don't
+// change.
+func updateSeries(rootEntry *Entry, topic string, fileExt string) {
+	entry := rootEntry.lookup(strings.Trim(topic, "/"))
+	if entry == nil {
+		panic("Unknown series topic " + topic + ", terminating.")
+	}
+
+	// Only notes that really live in the topic can link to each other.
+	notes := Notes{}
+	for _, note := range entry.notes {
+		if note.link == "" {
+			notes = append(notes, note)
+		}
+	}
+
+	sort.Stable(notes)
+	sortPinned(notes)
+
+	for i, note := range notes {
+		// Other formats can be linked to, but we can't write into them.
+		if note.format != nil {
+			continue
+		}
+
+		content, err := ioutil.ReadFile(note.path)
+		if err != nil {
+			panic(err)
+		}
+
+		newContent := withSeriesNav(string(content), seriesNav(notes, i, fileExt))
+		if newContent == string(content) {
+			continue
+		}
+
+		if err := ioutil.WriteFile(note.path, []byte(newContent), 0644); err != nil {
+			panic(err)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+// Catalog of the fenced code blocks in all notes, grouped by language and
+// then topic.  Each snippet is titled after the nearest heading above it, and
+// links back to the line it starts on (in GitHub's plain view, since the
+// rendered one has no line anchors).
+
+// A single snippet.  These also get written out as JSON, so the fields are
+// exported.
+type Snippet struct {
+	Title string `json:"title"`
+	Note  string `json:"note"`
+	Line  int    `json:"line"`
+	Code  string `json:"code"`
+}
+
+// Snippets by language, then by topic.
+type SnippetCatalog map[string]map[string][]Snippet
+
+// Pull the code blocks out of a note, which lives at `relPath`.  Blocks
+// without a language are left out, as are our own query blocks.
+func extractSnippets(content string, relPath string, fileExt string) map[string][]Snippet {
+	snippets := map[string][]Snippet{}
+
+	lines := strings.Split(content, "\n")
+	title := strings.TrimSuffix(pathBase(relPath), fileExt)
+
+	for i := 0; i < len(lines); i++ {
+		if level, text := parseHeading(lines[i]); level > 0 {
+			title = plainHeadingText(text)
+			continue
+		}
+
+		char, length, info := fenceMarker(lines[i])
+		if char == 0 {
+			continue
+		}
+
+		start := i
+		code := []string{}
+
+		for i+1 < len(lines) {
+			i++
+
+			closeChar, closeLength, closeInfo := fenceMarker(lines[i])
+			if closeChar == char && closeLength >= length && closeInfo == "" {
+				break
+			}
+			code = append(code, lines[i])
+		}
+
+		fields := strings.Fields(info)
+		if len(fields) == 0 || fields[0] == queryInfo {
+			continue
+		}
+
+		language := strings.ToLower(fields[0])
+		snippet := Snippet{Title: title, Note: relPath, Line: start + 1, Code: strings.Join(code, "\n")}
+		snippets[language] = append(snippets[language], snippet)
+	}
+
+	return snippets
+}
+
+// The last element of a slash-separated path.
+func pathBase(path string) string {
+	return path[strings.LastIndex(path, "/")+1:]
+}
+
+func buildSnippetCatalog(rootEntry Entry, languages []string, fileExt string) SnippetCatalog {
+	catalog := SnippetCatalog{}
+
+	rootEntry.walk("", func(path string, note Note) {
+		content, err := ioutil.ReadFile(note.path)
+		if err != nil {
+			panic(err)
+		}
+
+		for language, snippets := range extractSnippets(string(content), noteURL(path, note), fileExt) {
+			if len(languages) > 0 && containsFold(languages, language) == false {
+				continue
+			}
+
+			if catalog[language] == nil {
+				catalog[language] = map[string][]Snippet{}
+			}
+			catalog[language][path] = append(catalog[language][path], snippets...)
+		}
+	})
+
+	return catalog
+}
+
+func sortedKeys(items map[string][]Snippet) []string {
+	keys := make([]string, 0, len(items))
+	for key := range items {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+// Pick a fence that is longer than any run of backticks in `code`.
+func fenceFor(code string) string {
+	fence := "```"
+	for strings.Contains(code, fence) {
+		fence += "`"
+	}
+
+	return fence
+}
+
+func dumpSnippets(catalog SnippetCatalog) string {
+	result := "# Snippets\n"
+
+	languages := make([]string, 0, len(catalog))
+	for language := range catalog {
+		languages = append(languages, language)
+	}
+
+	sort.Strings(languages)
+
+	for _, language := range languages {
+		result += "\n## " + language + "\n"
+
+		for _, topic := range sortedKeys(catalog[language]) {
+			label := topic
+			if label == "" {
+				label = "(top level)"
+			}
+
+			result += "\n### " + label + "\n"
+
+			for _, snippet := range catalog[language][topic] {
+				fence := fenceFor(snippet.Code)
+
+				result += fmt.Sprintf("\n#### %s\n\nFrom [%s](%s?plain=1#L%d):\n\n", snippet.Title,
+					pathBase(snippet.Note), snippet.Note, snippet.Line)
+				result += fence + language + "\n" + snippet.Code + "\n" + fence + "\n"
+			}
+		}
+	}
+
+	return result
+}
+
+func dumpSnippetsJSON(catalog SnippetCatalog) string {
+	content, err := json.MarshalIndent(catalog, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+
+	return string(content) + "\n"
+}
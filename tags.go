@@ -0,0 +1,102 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Tags come from two places: the `tags:` list in front matter, and inline
+// `#hashtags` in the body of a note.  Which of these count is up to the
+// caller.
+
+var hashtagPattern = regexp.MustCompile(`(?:^|[^\w&/#])#([A-Za-z][\w/-]*\w|[A-Za-z])`)
+var urlPattern = regexp.MustCompile(`[a-zA-Z][a-zA-Z0-9+.-]*://\S+`)
+var linkTargetPattern = regexp.MustCompile(`\]\([^)]*\)`)
+
+// Find the inline hashtags in the body of a note, leaving out code, URLs
+// (which may have #fragments) and headings.
+func extractHashtags(body string) []string {
+	tags := []string{}
+
+	lines := strings.Split(body, "\n")
+	fenced := fencedLines(lines)
+
+	for i, line := range lines {
+		if level, _ := parseHeading(line); fenced[i] || level > 0 {
+			continue
+		}
+
+		line = codeSpanPattern.ReplaceAllString(line, "")
+		line = linkTargetPattern.ReplaceAllString(line, "]")
+		line = urlPattern.ReplaceAllString(line, "")
+
+		for _, match := range hashtagPattern.FindAllStringSubmatchIndex(line, -1) {
+			tag := line[match[2]:match[3]]
+			if colourCode(tag, line[:match[2]-1]) == false {
+				tags = append(tags, tag)
+			}
+		}
+	}
+
+	return tags
+}
+
+var colourContextPattern = regexp.MustCompile(`(?i)(?:colou?r|background|fill|stroke|border)[\w-]*\s*[:=]\s*$`)
+
+// Check whether a tag is really a colour, like `#fc0` or `#ffcc00`, given the
+// text that comes `before` it.  Plenty of words are hex as well (`#add`,
+// `#decade`), so it takes a digit or something like `color:` to tell.
+func colourCode(tag string, before string) bool {
+	length := len(tag)
+	if (length != 3 && length != 6 && length != 8) || hexPattern.MatchString(tag) == false {
+		return false
+	}
+
+	return strings.ContainsAny(tag, "0123456789") || colourContextPattern.MatchString(before)
+}
+
+// Add `tags` to `list`, skipping any that are already there (ignoring case).
+func mergeTags(list []string, tags []string) []string {
+	for _, tag := range tags {
+		if containsFold(list, tag) == false {
+			list = append(list, tag)
+		}
+	}
+
+	return list
+}
+
+// Settle the tags of every note, counting only the given sources
+// ("frontmatter" and/or "inline").
+func (entry *Entry) chooseTags(sources []string) {
+	useMeta := false
+	useInline := false
+
+	for _, source := range sources {
+		switch source {
+		case "frontmatter":
+			useMeta = true
+		case "inline":
+			useInline = true
+		default:
+			panic("Unknown tag source " + source + ", terminating.")
+		}
+	}
+
+	for i, note := range entry.notes {
+		tags := []string{}
+
+		if useMeta {
+			tags = mergeTags(tags, note.meta.list("tags"))
+		}
+		if useInline {
+			tags = mergeTags(tags, note.hashtags)
+		}
+
+		entry.notes[i].tagList = tags
+	}
+
+	for _, subEntry := range entry.subTopics {
+		subEntry.chooseTags(sources)
+	}
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtractHashtagsSkipsColours(t *testing.T) {
+	cases := map[string][]string{
+		"#bad #add #decade #facade":           {"bad", "add", "decade", "facade"},
+		"Brand colours are #ff0000 and #0a0.": {},
+		"Use color: #fed for the header.":     {},
+		"background:#facade; and #facade":     {"facade"},
+		"#cafe1234 or #deadbeef":              {"deadbeef"},
+	}
+
+	for body, want := range cases {
+		if got := extractHashtags(body); reflect.DeepEqual(got, want) == false {
+			t.Errorf("got %q from %q, want %q", got, body, want)
+		}
+	}
+}
@@ -0,0 +1,445 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	pathpkg "path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Transclusion pulls (part of) one note into another when publishing.  Two
+// forms are understood: Obsidian-style `![[note#Section]]`, where the note is
+// found by name anywhere in the tree, and Hugo-style `{{< include path >}}`,
+// where the path is relative to the including note.  Included headings are
+// shifted to nest under the heading that the directive sits in.  For an
+// Obsidian vault, wiki links and embeds of attachments are turned into
+// Markdown links as well, and notes can also be found by their aliases.
+
+var wikiEmbedPattern = regexp.MustCompile(`!\[\[([^\]|#]+)(?:#([^\]|]+))?(?:\|[^\]]*)?\]\]`)
+var includePattern = regexp.MustCompile(`\{\{<\s*include\s+"?([^">\s#]+)(?:#([^">]+))?"?\s*>\}\}`)
+
+type Transcluder struct {
+	// Notes by path relative to the root, and by name (with and without
+	// extension) for wiki-style embeds.
+	notes  map[string]Note
+	byName map[string]string
+
+	// Attachments by lowercase name, for embeds in a vault.
+	attachments map[string]string
+
+	fileExt  string
+	maxDepth int
+	vault    *ObsidianVault
+}
+
+func newTranscluder(rootEntry Entry, fileExt string, maxDepth int, vault *ObsidianVault) *Transcluder {
+	transcluder := &Transcluder{notes: map[string]Note{}, byName: map[string]string{},
+		attachments: map[string]string{}, fileExt: fileExt, maxDepth: maxDepth, vault: vault}
+
+	rootEntry.walk("", func(path string, note Note) {
+		relPath := noteURL(path, note)
+		transcluder.notes[relPath] = note
+
+		names := []string{note.name, strings.TrimSuffix(note.name, fileExt)}
+		if vault != nil {
+			names = append(names, note.meta.list("aliases")...)
+			names = append(names, note.meta.list("alias")...)
+		}
+
+		// The first note with a given name wins, like the index order.
+		for _, name := range names {
+			name = strings.ToLower(strings.TrimSpace(name))
+			if _, found := transcluder.byName[name]; found == false {
+				transcluder.byName[name] = relPath
+			}
+		}
+	})
+
+	rootEntry.walkAttachments("", func(path string, attachment Note) {
+		name := strings.ToLower(attachment.name)
+		if _, found := transcluder.attachments[name]; found == false {
+			transcluder.attachments[name] = noteURL(path, attachment)
+		}
+	})
+
+	return transcluder
+}
+
+// Find the note that a wiki-style embed names, or "" if there isn't one.
+func (transcluder *Transcluder) findByName(name string) string {
+	name = strings.TrimSpace(name)
+
+	if strings.Contains(name, "/") {
+		relPath := pathpkg.Clean(strings.TrimPrefix(name, "/"))
+		if strings.HasSuffix(relPath, transcluder.fileExt) == false {
+			relPath += transcluder.fileExt
+		}
+
+		if _, found := transcluder.notes[relPath]; found {
+			return relPath
+		}
+		return ""
+	}
+
+	return transcluder.byName[strings.ToLower(name)]
+}
+
+// Find the note that an include path names, relative to the note at
+// `fromPath`.
+func (transcluder *Transcluder) findByPath(target string, fromPath string) string {
+	relPath := pathpkg.Join(pathpkg.Dir(fromPath), target)
+	if strings.HasPrefix(target, "/") {
+		relPath = pathpkg.Clean(strings.TrimPrefix(target, "/"))
+	}
+
+	if _, found := transcluder.notes[relPath]; found {
+		return relPath
+	}
+
+	return ""
+}
+
+// Find the note that a wiki link in the note at `fromPath` names.  Vaults
+// that write relative links get those tried first.
+func (transcluder *Transcluder) resolve(name string, fromPath string) string {
+	name = strings.TrimSpace(name)
+
+	if transcluder.vault != nil && transcluder.vault.linkFormat == "relative" && strings.Contains(name, "/") {
+		target := name
+		if strings.HasSuffix(target, transcluder.fileExt) == false {
+			target += transcluder.fileExt
+		}
+
+		if relPath := transcluder.findByPath(target, fromPath); relPath != "" {
+			return relPath
+		}
+	}
+
+	return transcluder.findByName(name)
+}
+
+// Link from the note at `fromPath` to `toPath`, both relative to the root.
+func relativeLink(fromPath string, toPath string) string {
+	rel, err := filepath.Rel(filepath.FromSlash(pathpkg.Dir(fromPath)), filepath.FromSlash(toPath))
+	if err != nil {
+		rel = toPath
+	}
+
+	return strings.Replace(filepath.ToSlash(rel), " ", "%20", -1)
+}
+
+// Cut the section under the heading called `section` (by text or anchor) out
+// of `lines`, heading included.
+func extractSection(lines []string, section string) ([]string, bool) {
+	headings := parseHeadings(lines)
+
+	for i, heading := range headings {
+		if strings.EqualFold(heading.text, section) == false && githubSlug(heading.text) != githubSlug(section) {
+			continue
+		}
+
+		end := len(lines)
+		for _, next := range headings[i+1:] {
+			if next.level <= heading.level {
+				end = next.line
+				break
+			}
+		}
+
+		return lines[heading.line:end], true
+	}
+
+	return nil, false
+}
+
+// Move every heading in `lines` `shift` levels deeper, keeping within the six
+// levels that Markdown has.
+func shiftHeadings(lines []string, shift int) []string {
+	fenced := fencedLines(lines)
+	result := make([]string, len(lines))
+
+	for i, line := range lines {
+		result[i] = line
+
+		level, text := parseHeading(line)
+		if fenced[i] || level == 0 {
+			continue
+		}
+
+		level += shift
+		if level < 1 {
+			level = 1
+		} else if level > 6 {
+			level = 6
+		}
+
+		result[i] = strings.Repeat("#", level) + " " + text
+	}
+
+	return result
+}
+
+// Rewrite relative links in `content` from a note in `fromDir` so that they
+// work from a note in `toDir` (both relative to the root).
+func rebaseLinks(content string, fromDir string, toDir string) string {
+	if fromDir == toDir {
+		return content
+	}
+
+	return markdownLinkPattern.ReplaceAllStringFunc(content, func(link string) string {
+		match := markdownLinkPattern.FindStringSubmatchIndex(link)
+		target := link[match[2]:match[3]]
+
+		if isExternalLink(target) || strings.HasPrefix(target, "/") {
+			return link
+		}
+
+		rel, err := filepath.Rel(filepath.FromSlash(toDir), filepath.FromSlash(pathpkg.Join(fromDir, target)))
+		if err != nil {
+			return link
+		}
+
+		return link[:match[2]] + filepath.ToSlash(rel) + link[match[3]:]
+	})
+}
+
+// Produce the text that replaces a single directive in the note at
+// `fromPath`, which sits under a heading at `level`.  `stack` holds the notes
+// that are being included right now, outermost first.
+func (transcluder *Transcluder) include(relPath string, section string, fromPath string,
+	level int, stack []string) string {
+
+	for _, including := range stack {
+		if including == relPath {
+			fmt.Fprintln(os.Stderr, fromPath+": include cycle through "+relPath)
+			return "<!-- include cycle: " + relPath + " -->"
+		}
+	}
+
+	if len(stack) > transcluder.maxDepth {
+		fmt.Fprintln(os.Stderr, fromPath+": includes nested too deeply at "+relPath)
+		return "<!-- include depth exceeded: " + relPath + " -->"
+	}
+
+	content, err := ioutil.ReadFile(transcluder.notes[relPath].path)
+	if err != nil {
+		panic(err)
+	}
+
+	_, metaLines := parseFrontMatter(string(content))
+	lines := strings.Split(strings.TrimRight(string(content), "\n"), "\n")[metaLines:]
+
+	section = strings.TrimSpace(section)
+	if strings.HasPrefix(section, "^") {
+		blockLines, found := extractBlock(lines, section[1:])
+		if found == false {
+			fmt.Fprintln(os.Stderr, fromPath+": no block "+section+" in "+relPath)
+			return "<!-- missing block: " + relPath + "#" + section + " -->"
+		}
+
+		lines = blockLines
+	} else if section != "" {
+		sectionLines, found := extractSection(lines, section)
+		if found == false {
+			fmt.Fprintln(os.Stderr, fromPath+": no section "+section+" in "+relPath)
+			return "<!-- missing section: " + relPath + "#" + section + " -->"
+		}
+
+		lines = strings.Split(strings.TrimRight(strings.Join(sectionLines, "\n"), "\n"), "\n")
+	}
+
+	// Nest the shallowest included heading just under the current one.
+	minLevel := 0
+	for _, heading := range parseHeadings(lines) {
+		if minLevel == 0 || heading.level < minLevel {
+			minLevel = heading.level
+		}
+	}
+
+	if minLevel > 0 {
+		lines = shiftHeadings(lines, level+1-minLevel)
+	}
+
+	included := transcluder.expand(strings.Join(lines, "\n"), relPath, append(stack, relPath))
+	return rebaseLinks(included, pathpkg.Dir(relPath), pathpkg.Dir(fromPath))
+}
+
+// Where a directive sits on a line, and what to replace it with.
+type directiveSpan struct {
+	start   int
+	end     int
+	replace func(directive string) string
+}
+
+// Resolve every directive in `content`, which belongs to the note at
+// `relPath`.
+func (transcluder *Transcluder) expand(content string, relPath string, stack []string) string {
+	lines := strings.Split(content, "\n")
+	fenced := fencedLines(lines)
+	level := 0
+
+	for i, line := range lines {
+		if fenced[i] {
+			continue
+		}
+
+		if headingLevel, _ := parseHeading(line); headingLevel > 0 {
+			level = headingLevel
+			continue
+		}
+
+		// Every directive is found on the line as written, so that text from
+		// one include is never taken for a directive of the including note.
+		directives := []directiveSpan{}
+		find := func(pattern *regexp.Regexp, replace func(directive string) string) {
+			for _, loc := range pattern.FindAllStringIndex(line, -1) {
+				overlaps := false
+				for _, directive := range directives {
+					if loc[0] < directive.end && directive.start < loc[1] {
+						overlaps = true
+					}
+				}
+
+				if overlaps == false {
+					directives = append(directives, directiveSpan{loc[0], loc[1], replace})
+				}
+			}
+		}
+
+		if transcluder.vault != nil {
+			find(obsidianLinkPattern, func(directive string) string {
+				return transcluder.obsidianLink(directive, relPath, level, stack)
+			})
+		}
+
+		find(wikiEmbedPattern, func(directive string) string {
+			match := wikiEmbedPattern.FindStringSubmatch(directive)
+
+			// Embeds of anything other than notes (images, say) stay as they are.
+			target := transcluder.findByName(match[1])
+			if target == "" {
+				return directive
+			}
+
+			return transcluder.include(target, match[2], relPath, level, stack)
+		})
+
+		find(includePattern, func(directive string) string {
+			match := includePattern.FindStringSubmatch(directive)
+
+			target := transcluder.findByPath(match[1], relPath)
+			if target == "" {
+				fmt.Fprintln(os.Stderr, relPath+": cannot include "+match[1])
+				return directive
+			}
+
+			return transcluder.include(target, match[2], relPath, level, stack)
+		})
+
+		sort.Slice(directives, func(i int, j int) bool {
+			return directives[i].start < directives[j].start
+		})
+
+		expanded := ""
+		last := 0
+		for _, directive := range directives {
+			expanded += line[last:directive.start] + directive.replace(line[directive.start:directive.end])
+			last = directive.end
+		}
+
+		lines[i] = expanded + line[last:]
+	}
+
+	if transcluder.vault != nil {
+		lines = stripBlockIDs(lines)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Resolve a single Obsidian link or embed in the note at `relPath`.  Links
+// that don't lead anywhere are left as they are.
+func (transcluder *Transcluder) obsidianLink(directive string, relPath string, level int,
+	stack []string) string {
+
+	match := obsidianLinkPattern.FindStringSubmatch(directive)
+	embed := match[1] == "!"
+	name := strings.TrimSpace(match[2])
+	section := strings.TrimSpace(match[3])
+	label := strings.TrimSpace(match[4])
+
+	target := relPath
+	if name != "" {
+		target = transcluder.resolve(name, relPath)
+	}
+
+	if target == "" {
+		attachment, found := transcluder.attachments[strings.ToLower(pathBase(name))]
+		if found == false {
+			return directive
+		}
+
+		if label == "" {
+			label = pathBase(name)
+		}
+
+		link := relativeLink(relPath, attachment)
+		if embed && containsFold(imageExts, pathpkg.Ext(attachment)) {
+			return "![" + label + "](" + link + ")"
+		}
+		return "[" + label + "](" + link + ")"
+	}
+
+	if embed {
+		return transcluder.include(target, section, relPath, level, stack)
+	}
+
+	if label == "" {
+		label = name
+		if section != "" && strings.HasPrefix(section, "^") == false {
+			label = strings.TrimPrefix(name+" > "+section, " > ")
+		}
+	}
+
+	link := ""
+	if target != relPath {
+		link = relativeLink(relPath, target)
+	}
+	if section != "" && strings.HasPrefix(section, "^") == false {
+		link += "#" + githubSlug(section)
+	}
+	if link == "" {
+		link = pathBase(relPath)
+	}
+
+	return "[" + label + "](" + link + ")"
+}
+
+// Write a copy of every note to `outDir`, with all transclusions resolved.
+func publishNotes(rootEntry Entry, outDir string, fileExt string, maxDepth int, vault *ObsidianVault) {
+	transcluder := newTranscluder(rootEntry, fileExt, maxDepth, vault)
+
+	rootEntry.walk("", func(path string, note Note) {
+		relPath := noteURL(path, note)
+
+		content, err := ioutil.ReadFile(note.path)
+		if err != nil {
+			panic(err)
+		}
+
+		published := transcluder.expand(string(content), relPath, []string{relPath})
+
+		outPath := filepath.Join(outDir, filepath.FromSlash(relPath))
+		if err := os.MkdirAll(filepath.Dir(outPath), 0755); err != nil {
+			panic(err)
+		}
+
+		if err := ioutil.WriteFile(outPath, []byte(published), 0644); err != nil {
+			panic(err)
+		}
+	})
+}
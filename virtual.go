@@ -0,0 +1,85 @@
+package main
+
+import (
+	"strings"
+)
+
+// Notes can be listed under topics other than their directory, with a
+// `topics:` list in their front matter.  By default these are extra places
+// to find the note; with `topics_only: true` the note is only listed under
+// those topics.
+
+// Find the topic at `path`, creating it (and any parents) as needed.
+func (entry *Entry) ensureTopic(path string) *Entry {
+	for _, name := range strings.Split(path, "/") {
+		if name == "" {
+			continue
+		}
+
+		subEntry, found := entry.subTopics[Topic(name)]
+		if found == false {
+			newEntry := blankEntry()
+			newEntry.virtual = true
+			subEntry = &newEntry
+			entry.subTopics[Topic(name)] = subEntry
+		}
+
+		entry = subEntry
+	}
+
+	return entry
+}
+
+// Copy notes with a `topics:` list into those topics.  Links in every copy
+// still point to where the note really lives.
+func (rootEntry *Entry) placeVirtualTopics() {
+	type placement struct {
+		path string
+		note Note
+	}
+
+	placements := []placement{}
+
+	rootEntry.walk("", func(path string, note Note) {
+		if len(note.meta.list("topics")) > 0 {
+			placements = append(placements, placement{path, note})
+		}
+	})
+
+	for _, placement := range placements {
+		note := placement.note
+		only := note.meta.flag("topics_only")
+
+		// When the note leaves its own topic, the first copy takes its place.
+		copies := 0
+		if only {
+			entry := rootEntry.lookup(placement.path)
+
+			notes := Notes{}
+			for _, other := range entry.notes {
+				if other.path != note.path {
+					notes = append(notes, other)
+				}
+			}
+			entry.notes = notes
+		} else {
+			copies++
+		}
+
+		for _, topic := range note.meta.list("topics") {
+			topic = strings.Trim(topic, "/")
+			if topic == placement.path && only == false {
+				continue
+			}
+
+			entry := rootEntry.ensureTopic(topic)
+
+			listed := note
+			listed.link = noteURL(placement.path, note)
+			listed.duplicate = copies > 0
+			copies++
+
+			entry.notes = append(entry.notes, listed)
+		}
+	}
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	pathpkg "path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Output for a GitHub wiki.  Wikis have no directories, so every note becomes
+// a page at the top level, named after the note.  Names that clash once the
+// topics are flattened get their topic path as a prefix.  Links between
+// notes become wiki links, and `Home.md` and `_Sidebar.md` are built from the
+// topic tree.
+
+var wikiUnsafePattern = regexp.MustCompile(`[\\/:*?"<>|#%\s]+`)
+
+// Pages that GitHub gives a meaning of their own.
+var reservedWikiPages = []string{"Home", "_Sidebar", "_Footer"}
+
+func wikiName(name string) string {
+	return strings.Trim(wikiUnsafePattern.ReplaceAllString(strings.TrimSpace(name), "-"), "-")
+}
+
+// Pick a page name for every note, keyed by its path relative to the root.
+func wikiPageNames(rootEntry Entry, fileExt string) map[string]string {
+	relPaths := []string{}
+	stems := map[string]string{}
+	counts := map[string]int{}
+
+	for _, page := range reservedWikiPages {
+		counts[strings.ToLower(page)]++
+	}
+
+	rootEntry.walk("", func(path string, note Note) {
+		relPath := noteURL(path, note)
+		stem := wikiName(note.stem(fileExt))
+
+		relPaths = append(relPaths, relPath)
+		stems[relPath] = stem
+		counts[strings.ToLower(stem)]++
+	})
+
+	names := map[string]string{}
+	taken := map[string]bool{}
+
+	for _, page := range reservedWikiPages {
+		taken[strings.ToLower(page)] = true
+	}
+
+	for _, relPath := range relPaths {
+		name := stems[relPath]
+
+		if counts[strings.ToLower(name)] > 1 {
+			if dir := pathpkg.Dir(relPath); dir != "." {
+				name = wikiName(strings.Replace(dir, "/", "-", -1) + "-" + name)
+			}
+		}
+
+		base := name
+		for i := 2; taken[strings.ToLower(name)]; i++ {
+			name = fmt.Sprintf("%s-%d", base, i)
+		}
+
+		if name != stems[relPath] {
+			fmt.Fprintln(os.Stderr, relPath+": clashes with another page, using "+name)
+		}
+
+		taken[strings.ToLower(name)] = true
+		names[relPath] = name
+	}
+
+	return names
+}
+
+// Link to a page, in the form GitHub wikis use.
+func wikiLink(label string, page string) string {
+	label = strings.Replace(label, "|", "-", -1)
+	if label == page {
+		return "[[" + page + "]]"
+	}
+
+	return "[[" + label + "|" + page + "]]"
+}
+
+// Rewrite the links in a note at `relPath` for the wiki: links to notes go to
+// their pages, and links to anything else in the tree go from the root.
+func wikiLinks(content string, relPath string, pages map[string]string) string {
+	lines := strings.Split(content, "\n")
+	fenced := fencedLines(lines)
+
+	for i, line := range lines {
+		if fenced[i] {
+			continue
+		}
+
+		lines[i] = markdownLinkPattern.ReplaceAllStringFunc(line, func(link string) string {
+			match := markdownLinkPattern.FindStringSubmatchIndex(link)
+			target := link[match[2]:match[3]]
+
+			if isExternalLink(target) {
+				return link
+			}
+
+			anchor := ""
+			if hash := strings.Index(target, "#"); hash >= 0 {
+				target, anchor = target[:hash], target[hash:]
+			}
+
+			if unescaped, err := url.PathUnescape(target); err == nil {
+				target = unescaped
+			}
+
+			linked := pathpkg.Join(pathpkg.Dir(relPath), target)
+			if strings.HasPrefix(target, "/") {
+				linked = pathpkg.Clean(strings.TrimPrefix(target, "/"))
+			}
+
+			page, found := pages[linked]
+			if found == false {
+				return link[:match[2]] + strings.Replace(linked, " ", "%20", -1) + anchor + link[match[3]:]
+			}
+
+			label := link[1:strings.Index(link, "](")]
+			if anchor != "" {
+				return "[" + label + "](" + page + anchor + ")"
+			}
+
+			return wikiLink(label, page)
+		})
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Render the notes of `entry` and its topics for Home.md, with a heading per
+// topic.
+func (entry Entry) dumpWikiHome(path string, depth int, pages map[string]string, fileExt string) string {
+	result := ""
+
+	notes := entry.notes
+	sort.Stable(notes)
+	sortPinned(notes)
+
+	for _, note := range notes {
+		page := pages[noteURL(path, note)]
+		result += fmt.Sprintf("- %s [%s]\n", wikiLink(note.displayName(fileExt), page),
+			note.timestamp.Format("02 Jan 2006"))
+	}
+
+	keys := make([]string, 0, len(entry.subTopics))
+	for key := range entry.subTopics {
+		keys = append(keys, string(key))
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		subPath := key
+		if path != "" {
+			subPath = path + "/" + subPath
+		}
+
+		level := depth + 2
+		if level > 6 {
+			level = 6
+		}
+
+		result += "\n" + strings.Repeat("#", level) + " " + key + "\n\n"
+		result += entry.subTopics[Topic(key)].dumpWikiHome(subPath, depth+1, pages, fileExt)
+	}
+
+	return result
+}
+
+// Render `entry` as the nested list that goes in the sidebar.
+func (entry Entry) dumpWikiSidebar(path string, indent int, pages map[string]string, fileExt string) string {
+	result := ""
+	indentStr := strings.Repeat("  ", indent)
+
+	notes := entry.notes
+	sort.Stable(notes)
+	sortPinned(notes)
+
+	for _, note := range notes {
+		page := pages[noteURL(path, note)]
+		result += indentStr + "- " + wikiLink(note.displayName(fileExt), page) + "\n"
+	}
+
+	keys := make([]string, 0, len(entry.subTopics))
+	for key := range entry.subTopics {
+		keys = append(keys, string(key))
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		subPath := key
+		if path != "" {
+			subPath = path + "/" + subPath
+		}
+
+		result += indentStr + "- **" + key + "**\n"
+		result += entry.subTopics[Topic(key)].dumpWikiSidebar(subPath, indent+1, pages, fileExt)
+	}
+
+	return result
+}
+
+func writeWikiFile(path string, content []byte) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		panic(err)
+	}
+
+	if err := ioutil.WriteFile(path, content, 0644); err != nil {
+		panic(err)
+	}
+}
+
+// Write every note to `outDir` as a wiki page, with transclusions resolved,
+// along with the attachments and the Home and sidebar pages.
+func publishWiki(rootEntry Entry, outDir string, fileExt string, maxDepth int, vault *ObsidianVault) {
+	pages := wikiPageNames(rootEntry, fileExt)
+	transcluder := newTranscluder(rootEntry, fileExt, maxDepth, vault)
+
+	rootEntry.walk("", func(path string, note Note) {
+		relPath := noteURL(path, note)
+
+		content, err := ioutil.ReadFile(note.path)
+		if err != nil {
+			panic(err)
+		}
+
+		// GitHub renders some other formats as well, but we can only rewrite
+		// the links in Markdown.
+		if note.format == nil {
+			published := transcluder.expand(string(content), relPath, []string{relPath})
+			content = []byte(wikiLinks(published, relPath, pages))
+		}
+
+		writeWikiFile(filepath.Join(outDir, pages[relPath]+filepath.Ext(note.name)), content)
+	})
+
+	rootEntry.walkAttachments("", func(path string, attachment Note) {
+		content, err := ioutil.ReadFile(attachment.path)
+		if err != nil {
+			panic(err)
+		}
+
+		writeWikiFile(filepath.Join(outDir, filepath.FromSlash(noteURL(path, attachment))), content)
+	})
+
+	home := "# Home\n\n" + strings.TrimLeft(rootEntry.dumpWikiHome("", 0, pages, fileExt), "\n")
+	writeWikiFile(filepath.Join(outDir, "Home.md"), []byte(home))
+
+	sidebar := "**[[Home]]**\n\n" + rootEntry.dumpWikiSidebar("", 0, pages, fileExt)
+	writeWikiFile(filepath.Join(outDir, "_Sidebar.md"), []byte(sidebar))
+}